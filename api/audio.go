@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// audioFormat is an encoding for the audio kept for playback.
+type audioFormat struct {
+	extension   string
+	contentType string
+	// ffmpeg output options
+	args []string
+}
+
+// audioFormats lists the playback encodings by audio_format setting. "wav"
+// keeps the PCM file the pipeline works with.
+var audioFormats = map[string]audioFormat{
+	"opus": {extension: ".opus", contentType: "audio/ogg; codecs=opus", args: []string{"-c:a", "libopus", "-b:a", "32k", "-f", "ogg"}},
+	"mp3":  {extension: ".mp3", contentType: "audio/mpeg", args: []string{"-c:a", "libmp3lame", "-b:a", "64k", "-f", "mp3"}},
+	"wav":  {extension: ".wav", contentType: "audio/wav"},
+}
+
+// audioURL is where a job's audio is served.
+func audioURL(jobID string) string {
+	return "/job/" + jobID + "/audio"
+}
+
+// storedAudio returns the playback audio of a job and its format, preferring
+// compressed copies over a WAV that was not converted (yet).
+func storedAudio(jobID string) (string, audioFormat, bool) {
+	for _, name := range []string{"opus", "mp3", "wav"} {
+		format := audioFormats[name]
+		path := filepath.Join(cfg.DataDir, jobID+format.extension)
+		if _, err := os.Stat(path); err == nil {
+			return path, format, true
+		}
+	}
+	return "", audioFormat{}, false
+}
+
+// storeAudio converts the served WAV to the configured playback format once
+// transcription is finished and removes the WAV files. When conversion fails
+// the WAV stays and is served instead.
+func storeAudio(job *Job, logger *slog.Logger) {
+	logger = logger.With("stage", "audio")
+	os.Remove(filepath.Join(cfg.TempDir, job.ID+".wav"))
+
+	format := audioFormats[cfg.AudioFormat]
+	wav := filepath.Join(cfg.DataDir, job.ID+".wav")
+	if len(format.args) == 0 {
+		return
+	}
+	if _, err := os.Stat(wav); err != nil {
+		return
+	}
+	defer metrics.observeStage("encode", time.Now())
+
+	out := filepath.Join(cfg.DataDir, job.ID+format.extension)
+	tmp := out + ".tmp"
+	args := append([]string{"-i", wav, "-vn"}, format.args...)
+	cmd := exec.Command("ffmpeg", append(args, tmp, "-y")...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	metrics.observeToolExit("ffmpeg", err)
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		logger.Warn("Failed to compress audio, keeping WAV", "format", cfg.AudioFormat, "error", err, "output", outputTail(stderr.String()))
+		return
+	}
+
+	before, after := fileSize(wav), fileSize(out)
+	os.Remove(wav)
+	logger.Info("Stored compressed audio", "format", cfg.AudioFormat, "wav_bytes", before, "bytes", after)
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// handleAudio serves GET /job/{id}/audio with Range support, so players can
+// seek in long recordings without downloading them first.
+func handleAudio(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, HEAD, OPTIONS")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Range, Accept-Ranges, Content-Length")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobsMu.RLock()
+	job, exists := jobs[id]
+	var filename string
+	if exists {
+		filename = downloadName(job)
+	}
+	jobsMu.RUnlock()
+	if !exists {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	path, format, ok := storedAudio(id)
+	if !ok {
+		http.Error(w, "Audio not available", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Audio not available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read audio", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("inline", filename+format.extension))
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleAudioRange(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	cfg = &c
+
+	job := &Job{ID: "audio-test", Status: "done"}
+	jobsMu.Lock()
+	jobs[job.ID] = job
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, job.ID)
+		jobsMu.Unlock()
+	}()
+
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/audio-test/audio", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without audio, got %d", rec.Code)
+	}
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	os.WriteFile(filepath.Join(c.DataDir, "audio-test.wav"), []byte("RIFF"), 0644)
+	os.WriteFile(filepath.Join(c.DataDir, "audio-test.opus"), data, 0644)
+
+	req := httptest.NewRequest("GET", "/job/audio-test/audio", nil)
+	req.Header.Set("Range", "bytes=100-199")
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("Expected 206, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "audio/ogg; codecs=opus" {
+		t.Errorf("Expected the compressed audio to be preferred, got %q", ct)
+	}
+	if cr := rec.Header().Get("Content-Range"); cr != "bytes 100-199/1000" {
+		t.Errorf("Unexpected Content-Range %q", cr)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data[100:200]) {
+		t.Errorf("Unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestStoreAudioKeepsWAV(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.TempDir = t.TempDir()
+	c.AudioFormat = "wav"
+	cfg = &c
+
+	tmp := filepath.Join(c.TempDir, "store-test.wav")
+	served := filepath.Join(c.DataDir, "store-test.wav")
+	if err := writeWAV(tmp, make([]float32, 1600), 16000); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyFile(tmp, served); err != nil {
+		t.Fatalf("Failed to copy: %v", err)
+	}
+	if fileSize(served) != fileSize(tmp) {
+		t.Fatalf("Expected a full copy, got %d of %d bytes", fileSize(served), fileSize(tmp))
+	}
+
+	storeAudio(&Job{ID: "store-test"}, slog.Default())
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Error("Expected the temporary WAV to be removed")
+	}
+	if _, format, ok := storedAudio("store-test"); !ok || format.extension != ".wav" {
+		t.Error("Expected the served WAV to be kept with audio_format wav")
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// chatClient talks to an OpenAI-compatible /v1/chat/completions endpoint,
+// which covers OpenAI itself as well as vLLM, llama.cpp server, Ollama and
+// most hosted gateways.
+type chatClient struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// complete sends the conversation and returns the content of the first
+// choice.
+func (c *chatClient) complete(messages []chatMessage) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":       c.model,
+		"messages":    messages,
+		"temperature": 0,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("chat request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorOutput))
+		return "", fmt.Errorf("chat endpoint returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse chat response: %v", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("chat response has no choices")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// stripCodeFence removes a ```json ... ``` wrapper that models like to put
+// around structured answers.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+		s = s[nl+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Config holds the server settings. Values are resolved in increasing order of
+// precedence from built-in defaults, an optional JSON config file, VT_*
+// environment variables and command-line flags.
+type Config struct {
+	Addr    string `json:"addr"`
+	DataDir string `json:"data_dir"`
+	JobsDir string `json:"jobs_dir"`
+	TempDir string `json:"temp_dir"`
+
+	// Transcription engine
+	WhisperBin string `json:"whisper_bin"`
+	Model      string `json:"model"`
+	// Chunks with quality warnings are transcribed again with QualityModel,
+	// unless it is empty. Segments above NoSpeechThreshold and chunks below
+	// MinWordsPerMinute are flagged.
+	QualityModel      string  `json:"quality_model,omitempty"`
+	NoSpeechThreshold float64 `json:"no_speech_threshold"`
+	MinWordsPerMinute float64 `json:"min_words_per_minute"`
+	// Re-transcribe chunks whose detected language differs from the
+	// majority with the majority language forced
+	ForceLanguage bool `json:"force_language"`
+	// Encoding of the audio kept for playback: opus, mp3 or wav
+	AudioFormat string `json:"audio_format"`
+	// Pre-processing preset for jobs that do not pick one, and custom
+	// presets mapping names to lists of filters
+	AudioPreset  string              `json:"audio_preset,omitempty"`
+	AudioPresets map[string][]string `json:"audio_presets,omitempty"`
+	// Ask whisper for per-word timing
+	WordTimestamps bool `json:"word_timestamps"`
+	// JSON file with global and per-API-key glossaries
+	GlossaryFile string `json:"glossary_file,omitempty"`
+
+	// Audio larger than ChunkThresholdBytes is transcribed in chunks of
+	// ChunkSeconds each
+	ChunkThresholdBytes int64 `json:"chunk_threshold_bytes"`
+	ChunkSeconds        int   `json:"chunk_seconds"`
+
+	// Failed downloads and whisper crashes are retried up to DownloadRetries
+	// and EngineRetries times. The first retry waits the backoff in seconds,
+	// doubling with every further one.
+	DownloadRetries int `json:"download_retries"`
+	DownloadBackoff int `json:"download_backoff_seconds"`
+	EngineRetries   int `json:"engine_retries"`
+	EngineBackoff   int `json:"engine_backoff_seconds"`
+
+	// Readiness fails and new jobs are refused when the data directory has
+	// less free space than this
+	MinFreeBytes uint64 `json:"min_free_bytes"`
+
+	// Finished jobs lose their audio after AudioRetentionDays and everything
+	// but their transcript after JobRetentionDays, zero keeping them
+	// forever. The janitor applies this and removes orphaned temp files
+	// every JanitorInterval minutes.
+	AudioRetentionDays int `json:"audio_retention_days"`
+	JobRetentionDays   int `json:"job_retention_days"`
+	JanitorInterval    int `json:"janitor_interval_minutes"`
+	// Deleted jobs can be restored for TrashDays; zero deletes immediately
+	TrashDays int `json:"trash_days"`
+
+	// One of debug, info, warn or error
+	LogLevel string `json:"log_level"`
+
+	// Rendering of the saved {id}.txt and default for text exports: plain,
+	// paragraphs or timestamped. A pause of ParagraphGap seconds after a
+	// sentence starts a new paragraph.
+	TextStyle    string  `json:"text_style"`
+	ParagraphGap float64 `json:"paragraph_gap"`
+
+	// Speaker diarization: "" (off), "local" or "http". MaxSpeakers of zero
+	// lets the diarizer decide.
+	Diarizer    string `json:"diarizer"`
+	DiarizerURL string `json:"diarizer_url,omitempty"`
+	MaxSpeakers int    `json:"max_speakers"`
+
+	// Translation provider: "" (off), "openai" for an OpenAI-compatible chat
+	// endpoint or "libretranslate". TranslatorModel is used by "openai" only.
+	Translator       string `json:"translator"`
+	TranslatorURL    string `json:"translator_url,omitempty"`
+	TranslatorModel  string `json:"translator_model,omitempty"`
+	TranslatorAPIKey string `json:"translator_api_key,omitempty"`
+
+	// LLM post-processing through an OpenAI-compatible chat endpoint, off
+	// when LLMURL is empty. Transcripts longer than LLMContextChars are
+	// summarized in chunks. LLMPrompts overrides the built-in templates.
+	LLMURL          string            `json:"llm_url,omitempty"`
+	LLMModel        string            `json:"llm_model,omitempty"`
+	LLMAPIKey       string            `json:"llm_api_key,omitempty"`
+	LLMContextChars int               `json:"llm_context_chars"`
+	LLMPrompts      map[string]string `json:"llm_prompts,omitempty"`
+
+	// Custom PII detectors used alongside the built-in ones when a job asks
+	// for redaction. Only settable in the config file.
+	RedactionRules []RedactionRule `json:"redaction_rules,omitempty"`
+}
+
+// cfg is the active configuration. It starts out with the defaults so code
+// and tests that never call loadConfig behave like a stock server.
+var cfg = func() *Config {
+	c := defaultConfig()
+	c.fillDerived()
+	return c
+}()
+
+func defaultConfig() *Config {
+	return &Config{
+		Addr:                ":8081",
+		DataDir:             "/data",
+		TempDir:             "/tmp",
+		WhisperBin:          "whisper",
+		Model:               "tiny",
+		WordTimestamps:      true,
+		NoSpeechThreshold:   0.6,
+		AudioFormat:         "opus",
+		MinWordsPerMinute:   20,
+		ChunkThresholdBytes: 10 * 1024 * 1024,
+		ChunkSeconds:        120,
+		DownloadRetries:     3,
+		DownloadBackoff:     5,
+		EngineRetries:       2,
+		EngineBackoff:       10,
+		MinFreeBytes:        1 << 30,
+		JanitorInterval:     60,
+		TrashDays:           7,
+		LogLevel:            "info",
+		TextStyle:           styleParagraphs,
+		ParagraphGap:        1.5,
+		LLMContextChars:     12000,
+	}
+}
+
+// loadConfig builds the configuration from defaults, the config file, the
+// environment and args, and validates the result.
+func loadConfig(args []string) (*Config, error) {
+	c := defaultConfig()
+
+	fs := flag.NewFlagSet("v-transcribe", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("VT_CONFIG"), "path to a JSON config file")
+	addr := fs.String("addr", "", "listen address")
+	dataDir := fs.String("data-dir", "", "directory for transcripts, audio and job state")
+	jobsDir := fs.String("jobs-dir", "", "directory for job state (default <data-dir>/jobs)")
+	tempDir := fs.String("temp-dir", "", "directory for intermediate audio files")
+	whisperBin := fs.String("whisper-bin", "", "whisper executable")
+	model := fs.String("model", "", "whisper model name")
+	qualityModel := fs.String("quality-model", "", "larger whisper model for re-running chunks with quality warnings")
+	noSpeech := fs.Float64("no-speech-threshold", 0, "no-speech probability above which a segment is flagged")
+	forceLanguage := fs.Bool("force-language", false, "re-transcribe chunks in the majority language when chunks disagree")
+	minWPM := fs.Float64("min-wpm", 0, "words per minute below which a chunk is flagged")
+	audioFormat := fs.String("audio-format", "", "encoding of stored playback audio: opus, mp3 or wav")
+	audioPreset := fs.String("audio-preset", "", "default audio pre-processing preset, e.g. voice or lecture")
+	glossaryFile := fs.String("glossary-file", "", "JSON file with custom vocabulary and replacements")
+	wordTimestamps := fs.Bool("word-timestamps", true, "record per-word timestamps")
+	chunkThreshold := fs.Int64("chunk-threshold", 0, "audio size in bytes above which audio is chunked")
+	chunkSeconds := fs.Int("chunk-seconds", 0, "length of a transcription chunk in seconds")
+	downloadRetries := fs.Int("download-retries", 0, "retries of a failed download")
+	downloadBackoff := fs.Int("download-backoff", 0, "seconds before the first download retry, doubling after")
+	engineRetries := fs.Int("engine-retries", 0, "retries of a crashed whisper run")
+	engineBackoff := fs.Int("engine-backoff", 0, "seconds before the first whisper retry, doubling after")
+	diarizerFlag := fs.String("diarizer", "", `speaker diarization: "local", "http" or "" to disable`)
+	diarizerURL := fs.String("diarizer-url", "", "endpoint of the external diarization service")
+	maxSpeakers := fs.Int("max-speakers", 0, "upper bound on speakers per job (0 for automatic)")
+	translatorFlag := fs.String("translator", "", `translation provider: "openai", "libretranslate" or "" to disable`)
+	translatorURL := fs.String("translator-url", "", "endpoint of the translation provider")
+	translatorModel := fs.String("translator-model", "", "model name for the openai translator")
+	llmURL := fs.String("llm-url", "", "OpenAI-compatible chat endpoint for summaries")
+	llmModel := fs.String("llm-model", "", "model name for summaries")
+	llmContext := fs.Int("llm-context-chars", 0, "transcript characters sent per summary request")
+	textStyle := fs.String("text-style", "", "transcript text style: plain, paragraphs or timestamped")
+	paragraphGap := fs.Float64("paragraph-gap", 0, "pause in seconds after a sentence that starts a new paragraph")
+	logLevelFlag := fs.String("log-level", "", "log level: debug, info, warn or error")
+	audioRetention := fs.Int("audio-retention-days", 0, "delete audio of finished jobs after this many days (0 keeps it)")
+	jobRetention := fs.Int("job-retention-days", 0, "reduce finished jobs to their transcript after this many days (0 keeps them)")
+	trashDays := fs.Int("trash-days", 0, "days a deleted job can be restored (0 deletes immediately)")
+	janitorInterval := fs.Int("janitor-interval", 0, "minutes between janitor runs")
+	minFree := fs.Uint64("min-free-bytes", 0, "free space in the data directory required for readiness")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		f, err := os.Open(*configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		// A misspelled setting is an error rather than silently ignored
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", *configFile, err)
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	// Only flags given on the command line override the other sources
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			c.Addr = *addr
+		case "data-dir":
+			c.DataDir = *dataDir
+		case "jobs-dir":
+			c.JobsDir = *jobsDir
+		case "temp-dir":
+			c.TempDir = *tempDir
+		case "whisper-bin":
+			c.WhisperBin = *whisperBin
+		case "model":
+			c.Model = *model
+		case "quality-model":
+			c.QualityModel = *qualityModel
+		case "no-speech-threshold":
+			c.NoSpeechThreshold = *noSpeech
+		case "force-language":
+			c.ForceLanguage = *forceLanguage
+		case "min-wpm":
+			c.MinWordsPerMinute = *minWPM
+		case "audio-format":
+			c.AudioFormat = *audioFormat
+		case "audio-preset":
+			c.AudioPreset = *audioPreset
+		case "glossary-file":
+			c.GlossaryFile = *glossaryFile
+		case "word-timestamps":
+			c.WordTimestamps = *wordTimestamps
+		case "chunk-threshold":
+			c.ChunkThresholdBytes = *chunkThreshold
+		case "chunk-seconds":
+			c.ChunkSeconds = *chunkSeconds
+		case "download-retries":
+			c.DownloadRetries = *downloadRetries
+		case "download-backoff":
+			c.DownloadBackoff = *downloadBackoff
+		case "engine-retries":
+			c.EngineRetries = *engineRetries
+		case "engine-backoff":
+			c.EngineBackoff = *engineBackoff
+		case "audio-retention-days":
+			c.AudioRetentionDays = *audioRetention
+		case "job-retention-days":
+			c.JobRetentionDays = *jobRetention
+		case "trash-days":
+			c.TrashDays = *trashDays
+		case "janitor-interval":
+			c.JanitorInterval = *janitorInterval
+		case "min-free-bytes":
+			c.MinFreeBytes = *minFree
+		case "log-level":
+			c.LogLevel = *logLevelFlag
+		case "text-style":
+			c.TextStyle = *textStyle
+		case "paragraph-gap":
+			c.ParagraphGap = *paragraphGap
+		case "diarizer":
+			c.Diarizer = *diarizerFlag
+		case "diarizer-url":
+			c.DiarizerURL = *diarizerURL
+		case "max-speakers":
+			c.MaxSpeakers = *maxSpeakers
+		case "translator":
+			c.Translator = *translatorFlag
+		case "translator-url":
+			c.TranslatorURL = *translatorURL
+		case "translator-model":
+			c.TranslatorModel = *translatorModel
+		case "llm-url":
+			c.LLMURL = *llmURL
+		case "llm-model":
+			c.LLMModel = *llmModel
+		case "llm-context-chars":
+			c.LLMContextChars = *llmContext
+		}
+	})
+
+	c.fillDerived()
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// fillDerived sets values that default to something based on other settings.
+func (c *Config) fillDerived() {
+	if c.JobsDir == "" {
+		c.JobsDir = filepath.Join(c.DataDir, "jobs")
+	}
+}
+
+func (c *Config) applyEnv() error {
+	stringVars := map[string]*string{
+		"VT_ADDR":          &c.Addr,
+		"VT_DATA_DIR":      &c.DataDir,
+		"VT_JOBS_DIR":      &c.JobsDir,
+		"VT_TEMP_DIR":      &c.TempDir,
+		"VT_WHISPER_BIN":   &c.WhisperBin,
+		"VT_MODEL":         &c.Model,
+		"VT_QUALITY_MODEL": &c.QualityModel,
+		"VT_AUDIO_PRESET":  &c.AudioPreset,
+		"VT_AUDIO_FORMAT":  &c.AudioFormat,
+		"VT_GLOSSARY_FILE": &c.GlossaryFile,
+		"VT_LOG_LEVEL":     &c.LogLevel,
+		"VT_TEXT_STYLE":    &c.TextStyle,
+		"VT_DIARIZER":      &c.Diarizer,
+		"VT_DIARIZER_URL":  &c.DiarizerURL,
+
+		"VT_TRANSLATOR":         &c.Translator,
+		"VT_TRANSLATOR_URL":     &c.TranslatorURL,
+		"VT_TRANSLATOR_MODEL":   &c.TranslatorModel,
+		"VT_TRANSLATOR_API_KEY": &c.TranslatorAPIKey,
+
+		"VT_LLM_URL":     &c.LLMURL,
+		"VT_LLM_MODEL":   &c.LLMModel,
+		"VT_LLM_API_KEY": &c.LLMAPIKey,
+	}
+	for name, field := range stringVars {
+		if v := os.Getenv(name); v != "" {
+			*field = v
+		}
+	}
+
+	if v := os.Getenv("VT_CHUNK_THRESHOLD_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid VT_CHUNK_THRESHOLD_BYTES %q: %v", v, err)
+		}
+		c.ChunkThresholdBytes = n
+	}
+	if v := os.Getenv("VT_CHUNK_SECONDS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid VT_CHUNK_SECONDS %q: %v", v, err)
+		}
+		c.ChunkSeconds = n
+	}
+	if v := os.Getenv("VT_WORD_TIMESTAMPS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid VT_WORD_TIMESTAMPS %q: %v", v, err)
+		}
+		c.WordTimestamps = b
+	}
+	if v := os.Getenv("VT_FORCE_LANGUAGE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid VT_FORCE_LANGUAGE %q: %v", v, err)
+		}
+		c.ForceLanguage = b
+	}
+	if v := os.Getenv("VT_PARAGRAPH_GAP"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid VT_PARAGRAPH_GAP %q: %v", v, err)
+		}
+		c.ParagraphGap = n
+	}
+	if v := os.Getenv("VT_NO_SPEECH_THRESHOLD"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid VT_NO_SPEECH_THRESHOLD %q: %v", v, err)
+		}
+		c.NoSpeechThreshold = n
+	}
+	if v := os.Getenv("VT_MIN_WPM"); v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid VT_MIN_WPM %q: %v", v, err)
+		}
+		c.MinWordsPerMinute = n
+	}
+	if v := os.Getenv("VT_MAX_SPEAKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid VT_MAX_SPEAKERS %q: %v", v, err)
+		}
+		c.MaxSpeakers = n
+	}
+	if v := os.Getenv("VT_LLM_CONTEXT_CHARS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid VT_LLM_CONTEXT_CHARS %q: %v", v, err)
+		}
+		c.LLMContextChars = n
+	}
+	intVars := map[string]*int{
+		"VT_AUDIO_RETENTION_DAYS": &c.AudioRetentionDays,
+		"VT_JOB_RETENTION_DAYS":   &c.JobRetentionDays,
+		"VT_JANITOR_INTERVAL":     &c.JanitorInterval,
+		"VT_TRASH_DAYS":           &c.TrashDays,
+		"VT_DOWNLOAD_RETRIES":     &c.DownloadRetries,
+		"VT_DOWNLOAD_BACKOFF":     &c.DownloadBackoff,
+		"VT_ENGINE_RETRIES":       &c.EngineRetries,
+		"VT_ENGINE_BACKOFF":       &c.EngineBackoff,
+	}
+	for name, field := range intVars {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %v", name, v, err)
+			}
+			*field = n
+		}
+	}
+	if v := os.Getenv("VT_MIN_FREE_BYTES"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid VT_MIN_FREE_BYTES %q: %v", v, err)
+		}
+		c.MinFreeBytes = n
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("invalid addr %q: %v", c.Addr, err)
+	}
+	if c.DataDir == "" {
+		return fmt.Errorf("data_dir must not be empty")
+	}
+	if c.JobsDir == "" {
+		return fmt.Errorf("jobs_dir must not be empty")
+	}
+	if c.TempDir == "" {
+		return fmt.Errorf("temp_dir must not be empty")
+	}
+	if c.WhisperBin == "" {
+		return fmt.Errorf("whisper_bin must not be empty")
+	}
+	if c.Model == "" {
+		return fmt.Errorf("model must not be empty")
+	}
+	if c.NoSpeechThreshold <= 0 || c.NoSpeechThreshold > 1 {
+		return fmt.Errorf("no_speech_threshold must be in (0, 1], got %v", c.NoSpeechThreshold)
+	}
+	if c.MinWordsPerMinute < 0 {
+		return fmt.Errorf("min_words_per_minute must not be negative, got %v", c.MinWordsPerMinute)
+	}
+	if c.ChunkThresholdBytes <= 0 {
+		return fmt.Errorf("chunk_threshold_bytes must be positive, got %d", c.ChunkThresholdBytes)
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return fmt.Errorf("invalid log_level %q", c.LogLevel)
+	}
+	if !validTextStyle(c.TextStyle) {
+		return fmt.Errorf("invalid text_style %q", c.TextStyle)
+	}
+	if c.ParagraphGap <= 0 {
+		return fmt.Errorf("paragraph_gap must be positive, got %v", c.ParagraphGap)
+	}
+	switch c.Diarizer {
+	case "", "local":
+	case "http":
+		if c.DiarizerURL == "" {
+			return fmt.Errorf("diarizer_url is required for the http diarizer")
+		}
+	default:
+		return fmt.Errorf("unknown diarizer %q", c.Diarizer)
+	}
+	switch c.Translator {
+	case "":
+	case "openai":
+		if c.TranslatorURL == "" || c.TranslatorModel == "" {
+			return fmt.Errorf("translator_url and translator_model are required for the openai translator")
+		}
+	case "libretranslate":
+		if c.TranslatorURL == "" {
+			return fmt.Errorf("translator_url is required for the libretranslate translator")
+		}
+	default:
+		return fmt.Errorf("unknown translator %q", c.Translator)
+	}
+	if c.LLMURL != "" {
+		if c.LLMModel == "" {
+			return fmt.Errorf("llm_model is required when llm_url is set")
+		}
+		if c.LLMContextChars < 1000 {
+			return fmt.Errorf("llm_context_chars must be at least 1000, got %d", c.LLMContextChars)
+		}
+		if _, err := parsePrompts(c.LLMPrompts); err != nil {
+			return err
+		}
+	}
+	if _, ok := audioFormats[c.AudioFormat]; !ok {
+		return fmt.Errorf("invalid audio_format %q", c.AudioFormat)
+	}
+	if err := validatePresets(c.AudioPresets); err != nil {
+		return err
+	}
+	if _, err := c.presetFilters(c.AudioPreset); c.AudioPreset != "" && err != nil {
+		return err
+	}
+	if _, err := compileRedactionRules(c.RedactionRules); err != nil {
+		return err
+	}
+	if c.AudioRetentionDays < 0 || c.JobRetentionDays < 0 || c.TrashDays < 0 {
+		return fmt.Errorf("retention days must not be negative")
+	}
+	if c.JanitorInterval < 1 {
+		return fmt.Errorf("janitor_interval_minutes must be at least 1, got %d", c.JanitorInterval)
+	}
+	if c.DownloadRetries < 0 || c.DownloadBackoff < 0 || c.EngineRetries < 0 || c.EngineBackoff < 0 {
+		return fmt.Errorf("retries and backoff must not be negative")
+	}
+	if c.MaxSpeakers < 0 {
+		return fmt.Errorf("max_speakers must not be negative, got %d", c.MaxSpeakers)
+	}
+	if c.ChunkSeconds < 10 {
+		return fmt.Errorf("chunk_seconds must be at least 10, got %d", c.ChunkSeconds)
+	}
+	return nil
+}
+
+// handleGetConfig exposes the active configuration read-only.
+func handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, "GET, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(cfg.redacted())
+}
+
+// redacted returns a copy of the configuration that is safe to show, with
+// credentials masked.
+func (c *Config) redacted() *Config {
+	safe := *c
+	if safe.TranslatorAPIKey != "" {
+		safe.TranslatorAPIKey = "redacted"
+	}
+	if safe.LLMAPIKey != "" {
+		safe.LLMAPIKey = "redacted"
+	}
+	return &safe
+}
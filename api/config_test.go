@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	c, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("Failed to load default config: %v", err)
+	}
+
+	if c.Addr != ":8081" || c.DataDir != "/data" || c.TempDir != "/tmp" || c.Model != "tiny" {
+		t.Errorf("Unexpected defaults: %+v", c)
+	}
+	if c.JobsDir != "/data/jobs" {
+		t.Errorf("Expected jobs dir to default to /data/jobs, got %s", c.JobsDir)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	content := `{"addr": ":9000", "data_dir": "` + dir + `", "model": "base", "chunk_seconds": 60, "engine_retries": 4, "download_retries": 1}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("VT_MODEL", "small")
+	t.Setenv("VT_CHUNK_SECONDS", "90")
+	t.Setenv("VT_ENGINE_RETRIES", "3")
+
+	c, err := loadConfig([]string{"-config", file, "-chunk-seconds", "30", "-engine-retries", "5"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if c.Addr != ":9000" {
+		t.Errorf("Expected addr from file, got %s", c.Addr)
+	}
+	if c.JobsDir != filepath.Join(dir, "jobs") {
+		t.Errorf("Expected jobs dir derived from data dir, got %s", c.JobsDir)
+	}
+	if c.Model != "small" {
+		t.Errorf("Expected environment to override file, got model %s", c.Model)
+	}
+	if c.ChunkSeconds != 30 {
+		t.Errorf("Expected flag to override environment, got chunk_seconds %d", c.ChunkSeconds)
+	}
+	if p := c.retryPolicy(classEngineCrash); p.MaxRetries != 5 || p.Backoff != 10*time.Second {
+		t.Errorf("Expected engine retries from the flag, got %+v", p)
+	}
+	if p := c.retryPolicy(classTransientDownload); p.MaxRetries != 1 {
+		t.Errorf("Expected download retries from the file, got %+v", p)
+	}
+}
+
+func TestLoadConfigUnknownSetting(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"job_retention_day": 30}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig([]string{"-config", file}); err == nil || !strings.Contains(err.Error(), "job_retention_day") {
+		t.Errorf("Expected the misspelled setting to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	tests := [][]string{
+		{"-addr", "not-an-address"},
+		{"-chunk-seconds", "0"},
+		{"-chunk-threshold", "-1"},
+		{"-model", ""},
+		{"-translator", "openai", "-translator-url", "http://localhost/v1/chat/completions"},
+	}
+
+	for _, args := range tests {
+		if _, err := loadConfig(args); err == nil {
+			t.Errorf("Expected loadConfig(%v) to fail validation", args)
+		}
+	}
+}
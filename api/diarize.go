@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Diarizer assigns speaker labels to the segments of a transcript. The
+// implementation is picked by the diarizer setting: an external HTTP service
+// or the built-in embedding clustering.
+type Diarizer interface {
+	Diarize(logger *slog.Logger, audioFile string, segments []Segment) ([]Segment, error)
+}
+
+// diarizer is the configured implementation, nil when diarization is off.
+var diarizer Diarizer
+
+func newDiarizer(c *Config) (Diarizer, error) {
+	switch c.Diarizer {
+	case "":
+		return nil, nil
+	case "local":
+		return &localDiarizer{maxSpeakers: c.MaxSpeakers}, nil
+	case "http":
+		return &httpDiarizer{
+			url:         c.DiarizerURL,
+			maxSpeakers: c.MaxSpeakers,
+			client:      &http.Client{Timeout: 30 * time.Minute},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown diarizer %q", c.Diarizer)
+	}
+}
+
+// SpeakerTurn is a stretch of audio attributed to one speaker.
+type SpeakerTurn struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker string  `json:"speaker"`
+}
+
+// httpDiarizer posts the audio to an external diarization service, e.g. a
+// pyannote wrapper, which answers with speaker turns:
+//
+//	{"segments": [{"start": 0.0, "end": 4.2, "speaker": "SPEAKER_00"}, ...]}
+type httpDiarizer struct {
+	url         string
+	maxSpeakers int
+	client      *http.Client
+}
+
+func (d *httpDiarizer) Diarize(logger *slog.Logger, audioFile string, segments []Segment) ([]Segment, error) {
+	f, err := os.Open(audioFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stream the form so hours of audio never sit in memory
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		defer f.Close()
+		pw.CloseWithError(d.writeForm(mw, f, filepath.Base(audioFile)))
+	}()
+
+	resp, err := d.client.Post(d.url, mw.FormDataContentType(), pr)
+	if err != nil {
+		return nil, fmt.Errorf("diarization request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorOutput))
+		return nil, fmt.Errorf("diarization service returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		Segments []SpeakerTurn `json:"segments"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse diarization response: %v", err)
+	}
+
+	logger.Info("Received speaker turns", "turns", len(result.Segments))
+	return assignSpeakers(segments, result.Segments), nil
+}
+
+// writeForm writes the multipart request body: the audio as "file" and the
+// optional speaker limit.
+func (d *httpDiarizer) writeForm(mw *multipart.Writer, audio io.Reader, name string) error {
+	part, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return err
+	}
+	if d.maxSpeakers > 0 {
+		if err := mw.WriteField("max_speakers", strconv.Itoa(d.maxSpeakers)); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// assignSpeakers labels every segment with the speaker whose turns overlap it
+// the most. Labels are renumbered SPEAKER_1, SPEAKER_2, ... in order of first
+// appearance so they read naturally regardless of the diarizer's scheme.
+func assignSpeakers(segments []Segment, turns []SpeakerTurn) []Segment {
+	out := make([]Segment, len(segments))
+	labels := make(map[string]string)
+	for i, seg := range segments {
+		overlap := make(map[string]float64)
+		best, bestOverlap := "", 0.0
+		for _, turn := range turns {
+			o := min(seg.End, turn.End) - max(seg.Start, turn.Start)
+			if o <= 0 {
+				continue
+			}
+			overlap[turn.Speaker] += o
+			if overlap[turn.Speaker] > bestOverlap {
+				best, bestOverlap = turn.Speaker, overlap[turn.Speaker]
+			}
+		}
+
+		seg.Speaker = ""
+		if best != "" {
+			if _, ok := labels[best]; !ok {
+				labels[best] = fmt.Sprintf("SPEAKER_%d", len(labels)+1)
+			}
+			seg.Speaker = labels[best]
+		}
+		out[i] = seg
+	}
+	return out
+}
+
+// diarizeTranscript runs the optional diarization stage. Failures are logged
+// and leave the transcript without speakers rather than failing the job.
+func diarizeTranscript(job *Job, logger *slog.Logger, audioFile string, tr *Transcript) {
+	jobsMu.RLock()
+	wanted := job.Diarize
+	jobsMu.RUnlock()
+	if !wanted || diarizer == nil || len(tr.Segments) == 0 {
+		return
+	}
+
+	logger = logger.With("stage", "diarize")
+	updateJobStatusDetailed(job, "diarizing", 85, 100, 90, "")
+	defer metrics.observeStage("diarize", time.Now())
+
+	segments, err := diarizer.Diarize(logger, audioFile, tr.Segments)
+	if err != nil {
+		logger.Warn("Diarization failed, continuing without speakers", "error", err)
+		return
+	}
+	tr.Segments = segments
+	logger.Info("Assigned speakers to segments", "segments", len(segments))
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"math/cmplx"
+)
+
+// localDiarizer clusters per-segment voice embeddings without any external
+// service. The embedding is the mean and standard deviation of log mel
+// filterbank energies over the segment, which is crude next to a neural
+// speaker model but separates clearly different voices well enough for
+// interviews and meetings.
+type localDiarizer struct {
+	maxSpeakers int
+}
+
+const (
+	embedFrameSize = 400 // 25 ms at 16 kHz
+	embedHopSize   = 160 // 10 ms at 16 kHz
+	embedFFTSize   = 512
+	embedMelBands  = 24
+
+	// minEmbedFrames is the shortest segment (in frames) that gets its own
+	// embedding; shorter ones inherit the speaker of their neighbour.
+	minEmbedFrames = 30
+
+	// speakerDistance is the cosine distance below which two segments are
+	// considered to be the same speaker.
+	speakerDistance = 0.35
+)
+
+func (d *localDiarizer) Diarize(logger *slog.Logger, audioFile string, segments []Segment) ([]Segment, error) {
+	wav, err := openWAV(audioFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio: %v", err)
+	}
+	defer wav.Close()
+
+	bank := newMelFilterbank(wav.sampleRate)
+	embeddings := make([][]float64, len(segments))
+	for i, seg := range segments {
+		samples, err := wav.readRange(seg.Start, seg.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audio: %v", err)
+		}
+		embeddings[i] = bank.embed(samples)
+	}
+
+	labels := clusterEmbeddings(embeddings, d.maxSpeakers)
+	logger.Info("Clustered segments into speakers", "speakers", countLabels(labels))
+
+	out := make([]Segment, len(segments))
+	for i, seg := range segments {
+		seg.Speaker = ""
+		if labels[i] >= 0 {
+			seg.Speaker = fmt.Sprintf("SPEAKER_%d", labels[i]+1)
+		}
+		out[i] = seg
+	}
+	return out, nil
+}
+
+// clusterEmbeddings groups embeddings into speakers and returns a label per
+// embedding, numbered by first appearance. nil embeddings (segments too short
+// to judge) take the label of the closest labelled neighbour. maxSpeakers of
+// zero means no limit.
+func clusterEmbeddings(embeddings [][]float64, maxSpeakers int) []int {
+	labels := make([]int, len(embeddings))
+	vectors := normalizeEmbeddings(embeddings)
+
+	// Leader clustering: join the closest speaker or start a new one
+	var centroids [][]float64
+	for i, v := range vectors {
+		labels[i] = -1
+		if v == nil {
+			continue
+		}
+		best, dist := nearestCentroid(centroids, v)
+		if best < 0 || (dist > speakerDistance && (maxSpeakers <= 0 || len(centroids) < maxSpeakers)) {
+			centroids = append(centroids, append([]float64(nil), v...))
+			labels[i] = len(centroids) - 1
+			continue
+		}
+		labels[i] = best
+	}
+
+	// A few k-means passes fix assignments made before centroids settled
+	for iter := 0; iter < 5 && len(centroids) > 0; iter++ {
+		centroids = recomputeCentroids(vectors, labels, len(centroids))
+		changed := false
+		for i, v := range vectors {
+			if v == nil {
+				continue
+			}
+			if best, _ := nearestCentroid(centroids, v); best != labels[i] {
+				labels[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	fillShortSegments(labels)
+	return renumberLabels(labels)
+}
+
+// normalizeEmbeddings removes the mean over all segments, which cancels the
+// channel and room, and scales each vector to unit length.
+func normalizeEmbeddings(embeddings [][]float64) [][]float64 {
+	var mean []float64
+	count := 0
+	for _, e := range embeddings {
+		if e == nil {
+			continue
+		}
+		if mean == nil {
+			mean = make([]float64, len(e))
+		}
+		for j, x := range e {
+			mean[j] += x
+		}
+		count++
+	}
+	for j := range mean {
+		mean[j] /= float64(count)
+	}
+
+	out := make([][]float64, len(embeddings))
+	for i, e := range embeddings {
+		if e == nil {
+			continue
+		}
+		v := make([]float64, len(e))
+		norm := 0.0
+		for j, x := range e {
+			v[j] = x - mean[j]
+			norm += v[j] * v[j]
+		}
+		norm = math.Sqrt(norm)
+		if norm > 0 {
+			for j := range v {
+				v[j] /= norm
+			}
+		}
+		out[i] = v
+	}
+	return out
+}
+
+func nearestCentroid(centroids [][]float64, v []float64) (int, float64) {
+	best, bestDist := -1, math.Inf(1)
+	for i, c := range centroids {
+		if d := cosineDistance(c, v); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best, bestDist
+}
+
+func recomputeCentroids(vectors [][]float64, labels []int, k int) [][]float64 {
+	centroids := make([][]float64, k)
+	for i, v := range vectors {
+		if v == nil || labels[i] < 0 {
+			continue
+		}
+		if centroids[labels[i]] == nil {
+			centroids[labels[i]] = make([]float64, len(v))
+		}
+		for j, x := range v {
+			centroids[labels[i]][j] += x
+		}
+	}
+	// Keep cluster indices stable; empty clusters get an unreachable centroid
+	for i := range centroids {
+		if centroids[i] == nil {
+			centroids[i] = []float64{}
+		}
+	}
+	return centroids
+}
+
+func cosineDistance(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return math.Inf(1)
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(na*nb)
+}
+
+// fillShortSegments gives unlabelled segments the label of the previous
+// labelled segment, or the next one at the start of the audio.
+func fillShortSegments(labels []int) {
+	last := -1
+	for i, l := range labels {
+		if l >= 0 {
+			last = l
+		} else if last >= 0 {
+			labels[i] = last
+		}
+	}
+	next := -1
+	for i := len(labels) - 1; i >= 0; i-- {
+		if labels[i] >= 0 {
+			next = labels[i]
+		} else {
+			labels[i] = next
+		}
+	}
+}
+
+// renumberLabels maps labels to 0, 1, 2, ... in order of first appearance.
+func renumberLabels(labels []int) []int {
+	mapping := make(map[int]int)
+	out := make([]int, len(labels))
+	for i, l := range labels {
+		if l < 0 {
+			out[i] = -1
+			continue
+		}
+		if _, ok := mapping[l]; !ok {
+			mapping[l] = len(mapping)
+		}
+		out[i] = mapping[l]
+	}
+	return out
+}
+
+func countLabels(labels []int) int {
+	seen := make(map[int]bool)
+	for _, l := range labels {
+		if l >= 0 {
+			seen[l] = true
+		}
+	}
+	return len(seen)
+}
+
+// melFilterbank turns audio frames into log mel band energies.
+type melFilterbank struct {
+	filters [][]float64
+	window  []float64
+}
+
+func newMelFilterbank(sampleRate int) *melFilterbank {
+	toMel := func(f float64) float64 { return 2595 * math.Log10(1+f/700) }
+	fromMel := func(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+
+	bins := embedFFTSize/2 + 1
+	low, high := toMel(80), toMel(math.Min(7600, float64(sampleRate)/2))
+	points := make([]int, embedMelBands+2)
+	for i := range points {
+		hz := fromMel(low + (high-low)*float64(i)/float64(embedMelBands+1))
+		points[i] = int(math.Floor(hz * embedFFTSize / float64(sampleRate)))
+	}
+
+	filters := make([][]float64, embedMelBands)
+	for b := range filters {
+		filters[b] = make([]float64, bins)
+		left, center, right := points[b], points[b+1], points[b+2]
+		for k := left; k < center; k++ {
+			filters[b][k] = float64(k-left) / float64(max(center-left, 1))
+		}
+		for k := center; k < right && k < bins; k++ {
+			filters[b][k] = float64(right-k) / float64(max(right-center, 1))
+		}
+	}
+
+	window := make([]float64, embedFrameSize)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(embedFrameSize-1))
+	}
+	return &melFilterbank{filters: filters, window: window}
+}
+
+// embed returns the mean and standard deviation of the log mel energies of
+// samples, or nil when there are too few frames for a reliable estimate.
+func (m *melFilterbank) embed(samples []float32) []float64 {
+	frames := 0
+	sum := make([]float64, embedMelBands)
+	sumSq := make([]float64, embedMelBands)
+	buf := make([]complex128, embedFFTSize)
+
+	for start := 0; start+embedFrameSize <= len(samples); start += embedHopSize {
+		for i := range buf {
+			buf[i] = 0
+		}
+		for i := 0; i < embedFrameSize; i++ {
+			buf[i] = complex(float64(samples[start+i])*m.window[i], 0)
+		}
+		fft(buf)
+
+		for b, filter := range m.filters {
+			energy := 1e-10
+			for k, w := range filter {
+				if w > 0 {
+					energy += w * (real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k]))
+				}
+			}
+			e := math.Log(energy)
+			sum[b] += e
+			sumSq[b] += e * e
+		}
+		frames++
+	}
+
+	if frames < minEmbedFrames {
+		return nil
+	}
+
+	out := make([]float64, 2*embedMelBands)
+	for b := range sum {
+		mean := sum[b] / float64(frames)
+		out[b] = mean
+		out[embedMelBands+b] = math.Sqrt(math.Max(sumSq[b]/float64(frames)-mean*mean, 0))
+	}
+	return out
+}
+
+// fft is an in-place iterative radix-2 FFT; len(a) must be a power of two.
+func fft(a []complex128) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := a[start+k]
+				v := a[start+k+size/2] * w
+				a[start+k] = u + v
+				a[start+k+size/2] = u - v
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssignSpeakers(t *testing.T) {
+	segments := []Segment{
+		{ID: 0, Start: 0, End: 4},
+		{ID: 1, Start: 4, End: 8},
+		{ID: 2, Start: 8, End: 10},
+		{ID: 3, Start: 20, End: 22},
+	}
+	turns := []SpeakerTurn{
+		{Start: 0, End: 4.5, Speaker: "SPEAKER_07"},
+		{Start: 4.5, End: 9.5, Speaker: "SPEAKER_02"},
+		{Start: 9.5, End: 12, Speaker: "SPEAKER_07"},
+	}
+
+	got := assignSpeakers(segments, turns)
+	want := []string{"SPEAKER_1", "SPEAKER_2", "SPEAKER_2", ""}
+	for i, seg := range got {
+		if seg.Speaker != want[i] {
+			t.Errorf("Segment %d: expected speaker %q, got %q", i, want[i], seg.Speaker)
+		}
+	}
+}
+
+func TestClusterEmbeddings(t *testing.T) {
+	a := []float64{1, 0.1, 0}
+	b := []float64{0, 0.1, 1}
+	embeddings := [][]float64{a, b, nil, a, b, a}
+
+	labels := clusterEmbeddings(embeddings, 0)
+	want := []int{0, 1, 1, 0, 1, 0}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Fatalf("Expected labels %v, got %v", want, labels)
+		}
+	}
+
+	if labels := clusterEmbeddings(embeddings, 1); countLabels(labels) != 1 {
+		t.Errorf("Expected max_speakers=1 to yield one speaker, got %v", labels)
+	}
+}
+
+func TestLocalDiarizerSeparatesVoices(t *testing.T) {
+	const rate = 16000
+	// Alternate two synthetic "voices" with different spectra every 2 seconds
+	voices := [][]float64{{180, 360, 540}, {1200, 2400, 3600}}
+	var samples []float32
+	var segments []Segment
+	for i := 0; i < 6; i++ {
+		freqs := voices[i%2]
+		for n := 0; n < 2*rate; n++ {
+			v := 0.0
+			for _, f := range freqs {
+				v += 0.2 * math.Sin(2*math.Pi*f*float64(n)/rate)
+			}
+			samples = append(samples, float32(v))
+		}
+		segments = append(segments, Segment{ID: i, Start: float64(2 * i), End: float64(2*i + 2)})
+	}
+
+	path := filepath.Join(t.TempDir(), "voices.wav")
+	if err := writeWAV(path, samples, rate); err != nil {
+		t.Fatalf("Failed to write WAV: %v", err)
+	}
+
+	d := &localDiarizer{}
+	got, err := d.Diarize(slog.Default(), path, segments)
+	if err != nil {
+		t.Fatalf("Diarize failed: %v", err)
+	}
+	for i, seg := range got {
+		want := "SPEAKER_1"
+		if i%2 == 1 {
+			want = "SPEAKER_2"
+		}
+		if seg.Speaker != want {
+			t.Errorf("Segment %d: expected %s, got %q", i, want, seg.Speaker)
+		}
+	}
+}
+
+func TestHTTPDiarizerStreamsAudio(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "diarize.wav")
+	data := bytes.Repeat([]byte("audio"), 100000)
+	if err := os.WriteFile(audio, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Invalid form: %v", err)
+		}
+		if r.FormValue("max_speakers") != "2" {
+			t.Errorf("Expected max_speakers 2, got %q", r.FormValue("max_speakers"))
+		}
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("Missing file: %v", err)
+			return
+		}
+		got, _ := io.ReadAll(f)
+		if header.Filename != "diarize.wav" || !bytes.Equal(got, data) {
+			t.Errorf("Unexpected upload %s of %d bytes", header.Filename, len(got))
+		}
+		w.Write([]byte(`{"segments": [{"start": 0, "end": 5, "speaker": "A"}]}`))
+	}))
+	defer server.Close()
+
+	d := &httpDiarizer{url: server.URL, maxSpeakers: 2, client: server.Client()}
+	segments, err := d.Diarize(slog.Default(), audio, []Segment{{Start: 1, End: 2, Text: "hi"}})
+	if err != nil {
+		t.Fatalf("Diarize failed: %v", err)
+	}
+	if segments[0].Speaker != "SPEAKER_1" {
+		t.Errorf("Expected SPEAKER_1, got %q", segments[0].Speaker)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import "strings"
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit is one step of a diff: an equal or deleted token at a[A], or an
+// inserted token at b[B].
+type edit struct {
+	Kind editKind
+	A, B int
+}
+
+// maxDiffEdits bounds the work of the Myers search. Beyond it the two
+// sequences are so different that replacing one with the other is as good
+// a diff as any.
+const maxDiffEdits = 1000
+
+// diffTokens returns a shortest edit script turning a into b, found with
+// Myers' O(ND) algorithm after stripping the common prefix and suffix.
+func diffTokens(a, b []string) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{Kind: editEqual, A: i, B: i})
+	}
+	core := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, e := range core {
+		e.A += prefix
+		e.B += prefix
+		edits = append(edits, e)
+	}
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, edit{Kind: editEqual, A: len(a) - i, B: len(b) - i})
+	}
+	return edits
+}
+
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, offset, n, m)
+			}
+		}
+	}
+	return replaceAll(n, m)
+}
+
+func backtrack(trace [][]int, offset, x, y int) []edit {
+	var edits []edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{Kind: editEqual, A: x, B: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{Kind: editInsert, A: x, B: y})
+			} else {
+				x--
+				edits = append(edits, edit{Kind: editDelete, A: x, B: y})
+			}
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(n, m int) []edit {
+	edits := make([]edit, 0, n+m)
+	for i := 0; i < n; i++ {
+		edits = append(edits, edit{Kind: editDelete, A: i})
+	}
+	for j := 0; j < m; j++ {
+		edits = append(edits, edit{Kind: editInsert, A: n, B: j})
+	}
+	return edits
+}
+
+// diffChunk is a run of words with the same fate, as served by the diff
+// endpoint.
+type diffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+var editOps = map[editKind]string{editEqual: "equal", editDelete: "delete", editInsert: "insert"}
+
+// diffWords compares two texts word by word and merges the result into
+// runs.
+func diffWords(from, to string) []diffChunk {
+	a, b := strings.Fields(from), strings.Fields(to)
+	var chunks []diffChunk
+	for _, e := range diffTokens(a, b) {
+		var word string
+		if e.Kind == editInsert {
+			word = b[e.B]
+		} else {
+			word = a[e.A]
+		}
+		op := editOps[e.Kind]
+		if len(chunks) > 0 && chunks[len(chunks)-1].Op == op {
+			chunks[len(chunks)-1].Text += " " + word
+			continue
+		}
+		chunks = append(chunks, diffChunk{Op: op, Text: word})
+	}
+	return chunks
+}
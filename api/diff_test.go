@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// applyEdits rebuilds b from a and an edit script, checking it is consistent.
+func applyEdits(t *testing.T, a, b []string, edits []edit) []string {
+	var out []string
+	nextA := 0
+	for _, e := range edits {
+		switch e.Kind {
+		case editEqual:
+			if e.A != nextA || a[e.A] != b[e.B] {
+				t.Fatalf("Bad equal edit %+v", e)
+			}
+			out = append(out, a[e.A])
+			nextA++
+		case editDelete:
+			if e.A != nextA {
+				t.Fatalf("Bad delete edit %+v", e)
+			}
+			nextA++
+		case editInsert:
+			out = append(out, b[e.B])
+		}
+	}
+	if nextA != len(a) {
+		t.Fatalf("Edit script consumed %d of %d tokens", nextA, len(a))
+	}
+	return out
+}
+
+func TestDiffTokens(t *testing.T) {
+	tests := [][2]string{
+		{"the quick brown fox", "the quick red fox jumps"},
+		{"", "new text"},
+		{"old text", ""},
+		{"a b c a b b a", "c b a b a c"},
+		{"same words", "same words"},
+	}
+	for _, tt := range tests {
+		a, b := strings.Fields(tt[0]), strings.Fields(tt[1])
+		edits := diffTokens(a, b)
+		if got := applyEdits(t, a, b, edits); strings.Join(got, " ") != strings.Join(b, " ") {
+			t.Errorf("diff(%q, %q) rebuilds %q", tt[0], tt[1], got)
+		}
+	}
+
+	// The classic example from Myers' paper has 5 edits
+	edits := diffTokens(strings.Split("abcabba", ""), strings.Split("cbabac", ""))
+	changes := 0
+	for _, e := range edits {
+		if e.Kind != editEqual {
+			changes++
+		}
+	}
+	if changes != 5 {
+		t.Errorf("Expected a shortest script of 5 edits, got %d", changes)
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	got := diffWords("we wreck a nice beach today", "we recognize speech today")
+	want := []diffChunk{
+		{Op: "equal", Text: "we"},
+		{Op: "delete", Text: "wreck a nice beach"},
+		{Op: "insert", Text: "recognize speech"},
+		{Op: "equal", Text: "today"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Chunk %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// document is the layout shared by the Markdown, HTML and DOCX exports.
+type document struct {
+	Title      string
+	Channel    string
+	UploadDate string
+	Thumbnail  string
+	VideoURL   string
+	Summary    string
+	KeyPoints  []string
+	Chapters   []docLink
+	Paragraphs []docParagraph
+}
+
+// docLink is a timestamp pointing into the video.
+type docLink struct {
+	Title     string
+	Timestamp string
+	URL       string
+}
+
+type docParagraph struct {
+	// Heading is set on the first paragraph of a chapter
+	Heading   string
+	Timestamp string
+	URL       string
+	Text      string
+}
+
+func buildDocument(job *Job, opts formatOptions) *document {
+	doc := &document{
+		Title:      job.Title,
+		Channel:    job.ChannelName,
+		UploadDate: job.UploadDate,
+		Thumbnail:  job.Thumbnail,
+		VideoURL:   job.WebpageURL,
+	}
+	if doc.Title == "" {
+		doc.Title = "Transcript " + job.ID
+	}
+	if doc.VideoURL == "" {
+		doc.VideoURL = job.URL
+	}
+	if job.Summary != nil {
+		doc.Summary = job.Summary.Text
+		doc.KeyPoints = job.Summary.KeyPoints
+	}
+
+	videoID := youtubeVideoID(job.URL)
+	for _, ch := range jobChapters(job) {
+		doc.Chapters = append(doc.Chapters, docLink{
+			Title:     ch.Title,
+			Timestamp: formatTimestamp(ch.Start),
+			URL:       youtubeTimeURL(videoID, ch.Start),
+		})
+	}
+
+	if len(job.Segments) == 0 {
+		for _, text := range textParagraphs(job.Text) {
+			doc.Paragraphs = append(doc.Paragraphs, docParagraph{Text: text})
+		}
+		return doc
+	}
+	for _, p := range buildParagraphs(job.Segments, jobChapters(job), opts) {
+		doc.Paragraphs = append(doc.Paragraphs, docParagraph{
+			Heading:   p.Title,
+			Timestamp: formatTimestamp(p.Start),
+			URL:       youtubeTimeURL(videoID, p.Start),
+			Text:      paragraphText(job, p.Segments),
+		})
+	}
+	return doc
+}
+
+// youtubeVideoID extracts the video ID from the YouTube URL forms we accept,
+// or returns "" for anything else.
+func youtubeVideoID(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.Trim(u.Path, "/")
+	switch {
+	case host == "youtu.be":
+		return path
+	case strings.HasSuffix(host, "youtube.com"):
+		if v := u.Query().Get("v"); v != "" {
+			return v
+		}
+		for _, prefix := range []string{"shorts/", "embed/", "live/"} {
+			if id, ok := strings.CutPrefix(path, prefix); ok {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// youtubeTimeURL links to the video at the given offset, or returns "" when
+// the video ID is unknown.
+func youtubeTimeURL(videoID string, seconds float64) string {
+	if videoID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s&t=%ds", url.QueryEscape(videoID), int(seconds))
+}
+
+func renderMarkdown(job *Job, opts formatOptions) ([]byte, error) {
+	doc := buildDocument(job, opts)
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", markdownEscape(doc.Title))
+	if doc.Thumbnail != "" {
+		fmt.Fprintf(&b, "![%s](%s)\n\n", markdownEscape(doc.Title), doc.Thumbnail)
+	}
+	var meta []string
+	if doc.Channel != "" {
+		meta = append(meta, "**Channel:** "+markdownEscape(doc.Channel))
+	}
+	if doc.UploadDate != "" {
+		meta = append(meta, "**Published:** "+doc.UploadDate)
+	}
+	if doc.VideoURL != "" {
+		meta = append(meta, fmt.Sprintf("**Video:** <%s>", doc.VideoURL))
+	}
+	if len(meta) > 0 {
+		b.WriteString(strings.Join(meta, "  \n"))
+		b.WriteString("\n\n")
+	}
+
+	if doc.Summary != "" || len(doc.KeyPoints) > 0 {
+		b.WriteString("## Summary\n\n")
+		if doc.Summary != "" {
+			b.WriteString(markdownEscape(doc.Summary))
+			b.WriteString("\n\n")
+		}
+		for _, kp := range doc.KeyPoints {
+			fmt.Fprintf(&b, "- %s\n", markdownEscape(kp))
+		}
+		if len(doc.KeyPoints) > 0 {
+			b.WriteString("\n")
+		}
+	}
+
+	if len(doc.Chapters) > 0 {
+		b.WriteString("## Chapters\n\n")
+		for _, ch := range doc.Chapters {
+			fmt.Fprintf(&b, "- %s %s\n", markdownLink(ch.Timestamp, ch.URL), markdownEscape(ch.Title))
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Transcript\n\n")
+	for _, p := range doc.Paragraphs {
+		if p.Heading != "" {
+			fmt.Fprintf(&b, "### %s\n\n", markdownEscape(p.Heading))
+		}
+		if p.Timestamp != "" {
+			b.WriteString(markdownLink(p.Timestamp, p.URL))
+			b.WriteString(" ")
+		}
+		b.WriteString(markdownEscape(p.Text))
+		b.WriteString("\n\n")
+	}
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n"), nil
+}
+
+// markdownLink renders [HH:MM:SS](url), or just [HH:MM:SS] without a URL.
+func markdownLink(text, url string) string {
+	if url == "" {
+		return "\\[" + text + "\\]"
+	}
+	return fmt.Sprintf("[\\[%s\\]](%s)", text, url)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+var htmlTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.6; color: #222; }
+img.thumbnail { max-width: 100%; border-radius: 8px; }
+.meta { color: #666; }
+a.ts { font-family: monospace; text-decoration: none; margin-right: 0.5em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Thumbnail}}<p><img class="thumbnail" src="{{.Thumbnail}}" alt="{{.Title}}"></p>
+{{end}}<p class="meta">{{if .Channel}}{{.Channel}}{{end}}{{if .UploadDate}} · {{.UploadDate}}{{end}}{{if .VideoURL}} · <a href="{{.VideoURL}}">Watch the video</a>{{end}}</p>
+{{if or .Summary .KeyPoints}}<h2>Summary</h2>
+{{if .Summary}}<p>{{.Summary}}</p>
+{{end}}{{if .KeyPoints}}<ul>
+{{range .KeyPoints}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{end}}{{if .Chapters}}<h2>Chapters</h2>
+<ul>
+{{range .Chapters}}<li>{{if .URL}}<a class="ts" href="{{.URL}}">{{.Timestamp}}</a>{{else}}<span class="ts">{{.Timestamp}}</span>{{end}}{{.Title}}</li>
+{{end}}</ul>
+{{end}}<h2>Transcript</h2>
+{{range .Paragraphs}}{{if .Heading}}<h3>{{.Heading}}</h3>
+{{end}}<p>{{if .URL}}<a class="ts" href="{{.URL}}">[{{.Timestamp}}]</a>{{else if .Timestamp}}<span class="ts">[{{.Timestamp}}]</span>{{end}}{{.Text}}</p>
+{{end}}</body>
+</html>
+`))
+
+func renderHTML(job *Job, opts formatOptions) ([]byte, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, buildDocument(job, opts)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestYouTubeVideoID(t *testing.T) {
+	tests := map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ":              "dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ":                             "dQw4w9WgXcQ",
+		"https://m.youtube.com/watch?v=dQw4w9WgXcQ&t=10":           "dQw4w9WgXcQ",
+		"https://www.youtube.com/shorts/dQw4w9WgXcQ":               "dQw4w9WgXcQ",
+		"https://example.com/watch?v=dQw4w9WgXcQ":                  "",
+		"https://www.youtube.com/channel/UC38IQsAvIsxxjztdMZQtwHA": "",
+	}
+	for url, want := range tests {
+		if got := youtubeVideoID(url); got != want {
+			t.Errorf("youtubeVideoID(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func documentTestJob() *Job {
+	return &Job{
+		ID:          "document-test",
+		URL:         "https://youtu.be/abc123",
+		Title:       "Rock & <Roll>",
+		ChannelName: "Channel",
+		Thumbnail:   "https://i.ytimg.com/vi/abc123/hq.jpg",
+		Text:        "Intro words. Main words.",
+		Segments: []Segment{
+			{Start: 0, End: 3, Text: "Intro words."},
+			{Start: 65, End: 70, Text: "Main words."},
+		},
+		Chapters: []Chapter{{Start: 0, Title: "Start"}, {Start: 60, Title: "Main"}},
+		Summary:  &Summary{Text: "A short talk.", KeyPoints: []string{"Point one"}},
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	md, err := renderMarkdown(documentTestJob(), defaultFormatOptions())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, want := range []string{
+		"# Rock & \\<Roll\\>\n",
+		"![Rock & \\<Roll\\>](https://i.ytimg.com/vi/abc123/hq.jpg)",
+		"## Summary\n\nA short talk.\n\n- Point one\n",
+		"- [\\[00:01:00\\]](https://www.youtube.com/watch?v=abc123&t=60s) Main\n",
+		"### Main\n\n[\\[00:01:05\\]](https://www.youtube.com/watch?v=abc123&t=65s) Main words.\n",
+	} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", want, md)
+		}
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	html, err := renderHTML(documentTestJob(), defaultFormatOptions())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(string(html), "<h1>Rock &amp; &lt;Roll&gt;</h1>") {
+		t.Errorf("Expected escaped title, got:\n%s", html)
+	}
+	if !strings.Contains(string(html), `href="https://www.youtube.com/watch?v=abc123&amp;t=65s">[00:01:05]</a>Main words.`) {
+		t.Errorf("Expected timestamp link, got:\n%s", html)
+	}
+}
+
+func TestRenderDOCX(t *testing.T) {
+	data, err := renderDOCX(documentTestJob(), defaultFormatOptions())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("DOCX is not a zip archive: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(content)
+
+		// Every part must be well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed: %v", f.Name, err)
+			}
+		}
+	}
+	if !strings.Contains(parts["word/document.xml"], "Rock &amp; &lt;Roll&gt;") {
+		t.Error("Expected the title in the document")
+	}
+	if !strings.Contains(parts["word/_rels/document.xml.rels"], `Target="https://www.youtube.com/watch?v=abc123&amp;t=65s"`) {
+		t.Error("Expected a hyperlink relationship for the paragraph timestamp")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Static parts of a minimal WordprocessingML package. Styles are defined so
+// headings and links look right and show up in Word's navigation pane.
+const (
+	docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+</Types>`
+
+	docxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+	docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:cs="Calibri"/><w:sz w:val="22"/></w:rPr></w:rPrDefault>
+<w:pPrDefault><w:pPr><w:spacing w:after="160" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:pPr><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:sz w:val="48"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:before="240"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="32"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:pPr><w:spacing w:before="200"/><w:outlineLvl w:val="1"/></w:pPr><w:rPr><w:b/><w:sz w:val="26"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="ListBullet"><w:name w:val="List Bullet"/><w:basedOn w:val="Normal"/><w:pPr><w:ind w:left="360" w:hanging="360"/></w:pPr></w:style>
+<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style>
+<w:style w:type="character" w:styleId="Subtle"><w:name w:val="Subtle"/><w:rPr><w:color w:val="666666"/></w:rPr></w:style>
+</w:styles>`
+)
+
+// docxWriter accumulates the body of word/document.xml together with the
+// relationships for its hyperlinks.
+type docxWriter struct {
+	body bytes.Buffer
+	rels []string
+}
+
+func (d *docxWriter) paragraph(style string, runs ...string) {
+	d.body.WriteString("<w:p>")
+	if style != "" {
+		fmt.Fprintf(&d.body, `<w:pPr><w:pStyle w:val="%s"/></w:pPr>`, style)
+	}
+	for _, r := range runs {
+		d.body.WriteString(r)
+	}
+	d.body.WriteString("</w:p>")
+}
+
+// run returns a text run, optionally in a character style.
+func (d *docxWriter) run(text, style string) string {
+	var b strings.Builder
+	b.WriteString("<w:r>")
+	if style != "" {
+		fmt.Fprintf(&b, `<w:rPr><w:rStyle w:val="%s"/></w:rPr>`, style)
+	}
+	b.WriteString(`<w:t xml:space="preserve">`)
+	xml.EscapeText(&b, []byte(text))
+	b.WriteString("</w:t></w:r>")
+	return b.String()
+}
+
+// link returns a hyperlink run to url, registering its relationship.
+func (d *docxWriter) link(text, url string) string {
+	if url == "" {
+		return d.run(text, "")
+	}
+	id := fmt.Sprintf("rId%d", len(d.rels)+1)
+	var target strings.Builder
+	xml.EscapeText(&target, []byte(url))
+	d.rels = append(d.rels, fmt.Sprintf(`<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="%s" TargetMode="External"/>`, id, target.String()))
+	return fmt.Sprintf(`<w:hyperlink r:id="%s">%s</w:hyperlink>`, id, d.run(text, "Hyperlink"))
+}
+
+// renderDOCX writes the document as an Office Open XML package. The
+// thumbnail is linked rather than embedded so rendering never needs the
+// network.
+func renderDOCX(job *Job, opts formatOptions) ([]byte, error) {
+	doc := buildDocument(job, opts)
+	d := &docxWriter{}
+
+	d.paragraph("Title", d.run(doc.Title, ""))
+	var meta []string
+	if doc.Channel != "" {
+		meta = append(meta, doc.Channel)
+	}
+	if doc.UploadDate != "" {
+		meta = append(meta, doc.UploadDate)
+	}
+	if len(meta) > 0 {
+		d.paragraph("", d.run(strings.Join(meta, " · "), "Subtle"))
+	}
+	var links []string
+	if doc.VideoURL != "" {
+		links = append(links, d.link("Watch the video", doc.VideoURL))
+	}
+	if doc.Thumbnail != "" {
+		if len(links) > 0 {
+			links = append(links, d.run(" · ", ""))
+		}
+		links = append(links, d.link("Thumbnail", doc.Thumbnail))
+	}
+	if len(links) > 0 {
+		d.paragraph("", links...)
+	}
+
+	if doc.Summary != "" || len(doc.KeyPoints) > 0 {
+		d.paragraph("Heading1", d.run("Summary", ""))
+		if doc.Summary != "" {
+			d.paragraph("", d.run(doc.Summary, ""))
+		}
+		for _, kp := range doc.KeyPoints {
+			d.paragraph("ListBullet", d.run("• "+kp, ""))
+		}
+	}
+
+	if len(doc.Chapters) > 0 {
+		d.paragraph("Heading1", d.run("Chapters", ""))
+		for _, ch := range doc.Chapters {
+			d.paragraph("ListBullet", d.link(ch.Timestamp, ch.URL), d.run("  "+ch.Title, ""))
+		}
+	}
+
+	d.paragraph("Heading1", d.run("Transcript", ""))
+	for _, p := range doc.Paragraphs {
+		if p.Heading != "" {
+			d.paragraph("Heading2", d.run(p.Heading, ""))
+		}
+		var runs []string
+		if p.Timestamp != "" {
+			runs = append(runs, d.link("["+p.Timestamp+"]", p.URL), d.run(" ", ""))
+		}
+		runs = append(runs, d.run(p.Text, ""))
+		d.paragraph("", runs...)
+	}
+
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRootRels},
+		{"word/styles.xml", docxStyles},
+		{"word/_rels/document.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+` + strings.Join(d.rels, "\n") + `
+</Relationships>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<w:body>` + d.body.String() + `<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr></w:body>
+</w:document>`},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// errorClass groups pipeline failures by how they should be handled.
+type errorClass string
+
+const (
+	classTransientDownload errorClass = "transient_download"
+	classEngineCrash       errorClass = "engine_crash"
+	classInvalidInput      errorClass = "invalid_input"
+	classUnsupportedVideo  errorClass = "unsupported_video"
+	classInternal          errorClass = "internal"
+)
+
+// errorCode is the machine-readable reason a job failed, stored on the job as
+// error_code so clients can react to specific failures.
+type errorCode string
+
+const (
+	codeVideoPrivate       errorCode = "video_private"
+	codeVideoAgeRestricted errorCode = "video_age_restricted"
+	codeVideoGeoBlocked    errorCode = "video_geo_blocked"
+	codeVideoUnavailable   errorCode = "video_unavailable"
+	codeUnsupportedURL     errorCode = "unsupported_url"
+	codeDownloadFailed     errorCode = "download_failed"
+	codeInvalidAudio       errorCode = "invalid_audio"
+	codeOutOfMemory        errorCode = "out_of_memory"
+	codeEngineFailed       errorCode = "engine_failed"
+	codeEmptyTranscript    errorCode = "empty_transcript"
+	codeResumeImpossible   errorCode = "resume_impossible"
+	codeSaveFailed         errorCode = "save_failed"
+	codeInternal           errorCode = "internal_error"
+)
+
+// errorCodeClasses maps every code to the class that decides its retry policy.
+var errorCodeClasses = map[errorCode]errorClass{
+	codeVideoPrivate:       classUnsupportedVideo,
+	codeVideoAgeRestricted: classUnsupportedVideo,
+	codeVideoGeoBlocked:    classUnsupportedVideo,
+	codeVideoUnavailable:   classUnsupportedVideo,
+	codeUnsupportedURL:     classUnsupportedVideo,
+	codeDownloadFailed:     classTransientDownload,
+	codeInvalidAudio:       classInvalidInput,
+	codeOutOfMemory:        classEngineCrash,
+	codeEngineFailed:       classEngineCrash,
+	codeEmptyTranscript:    classInvalidInput, // silence or music, the same every run
+	codeResumeImpossible:   classInvalidInput,
+	codeSaveFailed:         classInternal,
+	codeInternal:           classInternal,
+}
+
+// errorMessages are the human messages shown for codes whose raw error would
+// mean little to a user.
+var errorMessages = map[errorCode]string{
+	codeVideoPrivate:       "This video is private",
+	codeVideoAgeRestricted: "This video is age-restricted and requires sign-in",
+	codeVideoGeoBlocked:    "This video is not available in the server's country",
+	codeVideoUnavailable:   "This video is unavailable",
+	codeUnsupportedURL:     "This URL is not supported",
+	codeOutOfMemory:        "Transcription ran out of memory",
+	codeEmptyTranscript:    "Transcription produced no text",
+}
+
+// maxErrorOutput bounds how much of a tool's stderr is kept with a job.
+const maxErrorOutput = 4096
+
+// pipelineError is an error raised by one of the processing stages together
+// with its code and the tail of the failing tool's stderr.
+type pipelineError struct {
+	Code   errorCode
+	Output string
+	Err    error
+}
+
+func (e *pipelineError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *pipelineError) Unwrap() error {
+	return e.Err
+}
+
+func newPipelineError(code errorCode, format string, args ...interface{}) error {
+	return &pipelineError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// newToolError is like newPipelineError but keeps the tail of the tool output.
+func newToolError(code errorCode, output string, format string, args ...interface{}) error {
+	return &pipelineError{Code: code, Output: outputTail(output), Err: fmt.Errorf(format, args...)}
+}
+
+// errorDetails returns the code and captured tool output of err, treating
+// unclassified errors as internal failures.
+func errorDetails(err error) (errorCode, string) {
+	var pe *pipelineError
+	if errors.As(err, &pe) {
+		return pe.Code, pe.Output
+	}
+	return codeInternal, ""
+}
+
+// classifyError returns the retry class of err.
+func classifyError(err error) errorClass {
+	code, _ := errorDetails(err)
+	if class, ok := errorCodeClasses[code]; ok {
+		return class
+	}
+	return classInternal
+}
+
+// outputTail keeps the last maxErrorOutput bytes of output, starting at a line
+// boundary where possible.
+func outputTail(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= maxErrorOutput {
+		return output
+	}
+	tail := output[len(output)-maxErrorOutput:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return tail
+}
+
+// downloadErrorMarkers map yt-dlp messages to codes. They are checked in order
+// against the lower-cased stderr, so more specific markers come first.
+var downloadErrorMarkers = []struct {
+	marker string
+	code   errorCode
+}{
+	{"private video", codeVideoPrivate},
+	{"sign in to confirm your age", codeVideoAgeRestricted},
+	{"age-restricted", codeVideoAgeRestricted},
+	{"inappropriate for some users", codeVideoAgeRestricted},
+	{"not made this video available in your country", codeVideoGeoBlocked},
+	{"not available in your country", codeVideoGeoBlocked},
+	{"blocked it in your country", codeVideoGeoBlocked},
+	{"members-only", codeVideoUnavailable},
+	{"has been removed", codeVideoUnavailable},
+	{"video unavailable", codeVideoUnavailable},
+	{"unsupported url", codeUnsupportedURL},
+	{"is not a valid url", codeUnsupportedURL},
+}
+
+// classifyDownloadError maps a failed yt-dlp run to an error code based on
+// what it printed to stderr. Unknown failures are assumed to be transient.
+func classifyDownloadError(stderr string) errorCode {
+	lower := strings.ToLower(stderr)
+	for _, m := range downloadErrorMarkers {
+		if strings.Contains(lower, m.marker) {
+			return m.code
+		}
+	}
+	return codeDownloadFailed
+}
+
+// classifyEngineError tells an out-of-memory kill apart from other whisper
+// failures.
+func classifyEngineError(err error, output string) errorCode {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGKILL {
+			return codeOutOfMemory
+		}
+	}
+
+	lower := strings.ToLower(output)
+	if strings.Contains(lower, "out of memory") || strings.Contains(lower, "memoryerror") ||
+		strings.Contains(lower, "cannot allocate memory") {
+		return codeOutOfMemory
+	}
+	return codeEngineFailed
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassifyDownloadError(t *testing.T) {
+	tests := []struct {
+		stderr   string
+		expected errorCode
+	}{
+		{"ERROR: [youtube] abc: Private video. Sign in if you've been granted access", codeVideoPrivate},
+		{"ERROR: [youtube] abc: Sign in to confirm your age. This video may be inappropriate for some users.", codeVideoAgeRestricted},
+		{"ERROR: [youtube] abc: The uploader has not made this video available in your country", codeVideoGeoBlocked},
+		{"ERROR: [youtube] abc: Video unavailable", codeVideoUnavailable},
+		{"ERROR: Unsupported URL: https://www.youtube.com/", codeUnsupportedURL},
+		{"ERROR: unable to download video data: HTTP Error 503: Service Unavailable", codeDownloadFailed},
+		{"", codeDownloadFailed},
+	}
+
+	for _, test := range tests {
+		if got := classifyDownloadError(test.stderr); got != test.expected {
+			t.Errorf("classifyDownloadError(%q) = %s; want %s", test.stderr, got, test.expected)
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	err := newToolError(codeVideoPrivate, "line 1\nERROR: Private video\n", "yt-dlp failed: exit status 1")
+	code, output := errorDetails(err)
+	if code != codeVideoPrivate {
+		t.Errorf("Expected code %s, got %s", codeVideoPrivate, code)
+	}
+	if output != "line 1\nERROR: Private video" {
+		t.Errorf("Unexpected captured output %q", output)
+	}
+	if classifyError(err) != classUnsupportedVideo {
+		t.Errorf("Expected private videos not to be retried, got class %s", classifyError(err))
+	}
+
+	if classifyError(newPipelineError(codeEmptyTranscript, "empty transcript generated")) != classInvalidInput {
+		t.Error("Expected empty transcripts not to be retried")
+	}
+
+	code, _ = errorDetails(errors.New("boom"))
+	if code != codeInternal {
+		t.Errorf("Expected unclassified errors to be internal, got %s", code)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	long := strings.Repeat("progress line\n", 1000) + "ERROR: final message"
+	tail := outputTail(long)
+	if len(tail) > maxErrorOutput {
+		t.Errorf("Expected tail of at most %d bytes, got %d", maxErrorOutput, len(tail))
+	}
+	if !strings.HasSuffix(tail, "ERROR: final message") {
+		t.Errorf("Expected tail to keep the last line, got %q", tail[len(tail)-40:])
+	}
+	if !strings.HasPrefix(tail, "progress line") {
+		t.Errorf("Expected tail to start at a line boundary, got %q", tail[:20])
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// exportFormat renders a job's transcript in one downloadable format.
+type exportFormat struct {
+	contentType string
+	extension   string
+	// needsSegments marks formats that cannot be built from plain text
+	needsSegments bool
+	render        func(job *Job, opts formatOptions) ([]byte, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"txt":  {contentType: "text/plain; charset=utf-8", extension: "txt", render: renderText},
+	"srt":  {contentType: "application/x-subrip; charset=utf-8", extension: "srt", needsSegments: true, render: renderSRT},
+	"vtt":  {contentType: "text/vtt; charset=utf-8", extension: "vtt", needsSegments: true, render: renderVTT},
+	"json": {contentType: "application/json", extension: "json", render: renderJSON},
+	"md":   {contentType: "text/markdown; charset=utf-8", extension: "md", render: renderMarkdown},
+	"html": {contentType: "text/html; charset=utf-8", extension: "html", render: renderHTML},
+	"docx": {contentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", extension: "docx", render: renderDOCX},
+}
+
+// handleExport serves GET /job/{id}/export?format=...&lang=... where lang
+// selects a finished translation instead of the original transcript and
+// revision an earlier version of an edited transcript (the latest by
+// default). Text formats also take style and breaks, see parseFormatOptions.
+func handleExport(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "txt"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		http.Error(w, "Unsupported export format", http.StatusBadRequest)
+		return
+	}
+	opts, err := parseFormatOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Rendering and writing happen on a copy, so a slow download never
+	// holds up the worker or other handlers waiting for jobsMu
+	jobsMu.RLock()
+	job, exists := jobs[id]
+	if exists {
+		job = snapshotJob(job)
+	}
+	jobsMu.RUnlock()
+	if !exists {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.Status != "done" {
+		http.Error(w, "Job is not finished", http.StatusConflict)
+		return
+	}
+
+	if r.URL.Query().Has("revision") && r.URL.Query().Has("lang") {
+		http.Error(w, "Translations are only available for the latest revision", http.StatusBadRequest)
+		return
+	}
+
+	// Named like /files downloads, after the title, e.g. "Talk.r2.srt"
+	base := downloadName(job)
+	filename := fmt.Sprintf("%s.%s", base, format.extension)
+	if v := r.URL.Query().Get("revision"); v != "" {
+		number, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+		view, err := revisionJob(job, number)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		job = view
+		filename = fmt.Sprintf("%s.r%d.%s", base, number, format.extension)
+	}
+	if lang := normalizeLanguage(r.URL.Query().Get("lang")); lang != "" {
+		localized, err := translatedJob(job, lang)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		job = localized
+		filename = fmt.Sprintf("%s.%s.%s", base, lang, format.extension)
+	}
+	if format.needsSegments && len(job.Segments) == 0 {
+		http.Error(w, "Timed segments are not available for this job", http.StatusConflict)
+		return
+	}
+
+	data, err := format.render(job, opts)
+	if err != nil {
+		http.Error(w, "Failed to render export", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	w.Write(data)
+}
+
+// snapshotJob copies a job so that it shares no maps or slices with the
+// original and can be read or encoded after jobsMu is released. The caller
+// holds jobsMu.
+func snapshotJob(job *Job) *Job {
+	view := *job
+	view.Tags = slices.Clone(job.Tags)
+	view.Chapters = slices.Clone(job.Chapters)
+	view.Segments = slices.Clone(job.Segments)
+	view.SpeakerNames = maps.Clone(job.SpeakerNames)
+	view.Revisions = slices.Clone(job.Revisions)
+	if job.Translations != nil {
+		view.Translations = make(map[string]*Translation, len(job.Translations))
+		for lang, tr := range job.Translations {
+			copied := *tr
+			copied.Segments = slices.Clone(tr.Segments)
+			view.Translations[lang] = &copied
+		}
+	}
+	if job.Summary != nil {
+		summary := *job.Summary
+		summary.KeyPoints = slices.Clone(job.Summary.KeyPoints)
+		summary.Chapters = slices.Clone(job.Summary.Chapters)
+		view.Summary = &summary
+	}
+	view.Substitutions = slices.Clone(job.Substitutions)
+	if job.Redactions != nil {
+		report := *job.Redactions
+		report.Counts = maps.Clone(job.Redactions.Counts)
+		report.Items = slices.Clone(job.Redactions.Items)
+		view.Redactions = &report
+	}
+	view.QualityWarnings = slices.Clone(job.QualityWarnings)
+	view.ChunkLanguages = slices.Clone(job.ChunkLanguages)
+	view.Attempts = slices.Clone(job.Attempts)
+	view.StageRetries = maps.Clone(job.StageRetries)
+	return &view
+}
+
+// renderText returns the transcript as text in the requested style, with
+// chapter titles and speaker names.
+func renderText(job *Job, opts formatOptions) ([]byte, error) {
+	return []byte(formatText(job, opts) + "\n"), nil
+}
+
+// segmentText joins segments into text, starting a "Speaker: " paragraph
+// whenever the speaker changes.
+func segmentText(job *Job, segments []Segment) string {
+	var b strings.Builder
+	current := ""
+	for i, seg := range segments {
+		if seg.Speaker != current {
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			current = seg.Speaker
+			b.WriteString(speakerName(job, current))
+			b.WriteString(": ")
+		} else if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(seg.Text)
+	}
+	return b.String()
+}
+
+// renderSRT returns the segments as SubRip subtitles.
+func renderSRT(job *Job, _ formatOptions) ([]byte, error) {
+	var b strings.Builder
+	for i, seg := range job.Segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n", i+1, formatSRTTime(seg.Start), formatSRTTime(seg.End))
+		if seg.Speaker != "" {
+			b.WriteString(speakerName(job, seg.Speaker))
+			b.WriteString(": ")
+		}
+		b.WriteString(seg.Text)
+		b.WriteString("\n\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// renderVTT returns the segments as WebVTT. When word timing is available,
+// every word after the first carries an inline <HH:MM:SS.mmm> timestamp tag
+// so players can highlight words as they are spoken.
+func renderVTT(job *Job, _ formatOptions) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, seg := range job.Segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n", i+1, formatVTTTime(seg.Start), formatVTTTime(seg.End))
+		if seg.Speaker != "" {
+			fmt.Fprintf(&b, "<v %s>", vttEscaper.Replace(speakerName(job, seg.Speaker)))
+		}
+		if len(seg.Words) == 0 {
+			b.WriteString(vttEscaper.Replace(seg.Text))
+		} else {
+			last := seg.Start
+			for j, w := range seg.Words {
+				if j > 0 {
+					b.WriteString(" ")
+				}
+				// Tags must increase strictly and stay inside the cue
+				if w.Start > last && w.Start < seg.End {
+					fmt.Fprintf(&b, "<%s>", formatVTTTime(w.Start))
+					last = w.Start
+				}
+				b.WriteString(vttEscaper.Replace(w.Word))
+			}
+		}
+		b.WriteString("\n\n")
+	}
+	return []byte(b.String()), nil
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// formatVTTTime formats seconds as HH:MM:SS.mmm.
+func formatVTTTime(seconds float64) string {
+	return strings.Replace(formatSRTTime(seconds), ",", ".", 1)
+}
+
+// exportDocument is the JSON export of a job.
+type exportDocument struct {
+	ID       string            `json:"id"`
+	Title    string            `json:"title,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Text     string            `json:"text"`
+	Speakers map[string]string `json:"speakers,omitempty"`
+	Segments []Segment         `json:"segments"`
+	// Finished translations by language, aligned with Segments
+	Translations map[string][]Segment `json:"translations,omitempty"`
+	// The transcript split by chapter, when the job has chapters
+	Sections []exportSection `json:"sections,omitempty"`
+}
+
+type exportSection struct {
+	Title string  `json:"title,omitempty"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+func renderJSON(job *Job, _ formatOptions) ([]byte, error) {
+	doc := exportDocument{
+		ID:       job.ID,
+		Title:    job.Title,
+		URL:      job.URL,
+		Text:     job.Text,
+		Segments: namedSegments(job, job.Segments),
+	}
+	for _, seg := range job.Segments {
+		if seg.Speaker != "" {
+			if doc.Speakers == nil {
+				doc.Speakers = make(map[string]string)
+			}
+			doc.Speakers[seg.Speaker] = speakerName(job, seg.Speaker)
+		}
+	}
+	if chapters := jobChapters(job); len(chapters) > 0 {
+		for _, sec := range splitSections(job.Segments, chapters) {
+			doc.Sections = append(doc.Sections, exportSection{
+				Title: sec.Title,
+				Start: sec.Start,
+				End:   sec.End,
+				Text:  segmentText(job, sec.Segments),
+			})
+		}
+	}
+	for lang, tr := range job.Translations {
+		if tr.Status != "done" || len(tr.Segments) == 0 {
+			continue
+		}
+		if doc.Translations == nil {
+			doc.Translations = make(map[string][]Segment)
+		}
+		doc.Translations[lang] = namedSegments(job, tr.Segments)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// namedSegments returns copies of segments with speaker labels replaced by
+// their display names.
+func namedSegments(job *Job, segments []Segment) []Segment {
+	out := make([]Segment, len(segments))
+	for i, seg := range segments {
+		if seg.Speaker != "" {
+			seg.Speaker = speakerName(job, seg.Speaker)
+		}
+		out[i] = seg
+	}
+	return out
+}
+
+// formatSRTTime formats seconds as HH:MM:SS,mmm.
+func formatSRTTime(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatSRTTime(t *testing.T) {
+	if got := formatSRTTime(3723.4567); got != "01:02:03,457" {
+		t.Errorf("Expected 01:02:03,457, got %s", got)
+	}
+}
+
+func TestRenderWithSpeakerNames(t *testing.T) {
+	job := &Job{
+		ID:   "export-test",
+		Text: "Hi. Hello. How are you?",
+		Segments: []Segment{
+			{ID: 0, Start: 0, End: 1, Text: "Hi.", Speaker: "SPEAKER_1"},
+			{ID: 1, Start: 1, End: 2, Text: "Hello.", Speaker: "SPEAKER_2"},
+			{ID: 2, Start: 2, End: 3.5, Text: "How are you?", Speaker: "SPEAKER_2"},
+		},
+		SpeakerNames: map[string]string{"SPEAKER_1": "Alice"},
+	}
+
+	text, _ := renderText(job, defaultFormatOptions())
+	if string(text) != "Alice: Hi.\n\nSPEAKER_2: Hello. How are you?\n" {
+		t.Errorf("Unexpected text export %q", text)
+	}
+
+	srt, _ := renderSRT(job, formatOptions{})
+	if !strings.Contains(string(srt), "1\n00:00:00,000 --> 00:00:01,000\nAlice: Hi.\n") {
+		t.Errorf("Unexpected SRT export %q", srt)
+	}
+	if !strings.Contains(string(srt), "3\n00:00:02,000 --> 00:00:03,500\nSPEAKER_2: How are you?\n") {
+		t.Errorf("Unexpected SRT export %q", srt)
+	}
+}
+
+func TestRenderTextWithChapters(t *testing.T) {
+	job := &Job{
+		ID:   "chapters-test",
+		Text: "Welcome. Let's start. First topic.",
+		Segments: []Segment{
+			{ID: 0, Start: 0, End: 2, Text: "Welcome."},
+			{ID: 1, Start: 2, End: 4, Text: "Let's start."},
+			{ID: 2, Start: 59, End: 64, Text: "First topic."},
+		},
+		Chapters: []Chapter{
+			{Start: 3, End: 60, Title: "Intro"},
+			{Start: 60, End: 120, Title: "Topic"},
+			{Start: 120, End: 180, Title: "Empty"},
+		},
+	}
+
+	sections := splitSections(job.Segments, job.Chapters)
+	if len(sections) != 3 || sections[0].Title != "" || sections[2].Title != "Topic" {
+		t.Fatalf("Unexpected sections %+v", sections)
+	}
+
+	text, _ := renderText(job, defaultFormatOptions())
+	want := "Welcome.\n\nIntro\n\nLet's start.\n\nTopic\n\nFirst topic.\n"
+	if string(text) != want {
+		t.Errorf("Expected %q, got %q", want, text)
+	}
+}
+
+func TestRenderVTTWithWordTimestamps(t *testing.T) {
+	job := &Job{
+		ID: "vtt-test",
+		Segments: []Segment{{
+			Start: 1, End: 3, Text: "Fish & chips", Speaker: "SPEAKER_1",
+			Words: []Word{
+				{Start: 1, End: 1.4, Word: "Fish"},
+				{Start: 1.5, End: 1.7, Word: "&"},
+				{Start: 1.8, End: 2.9, Word: "chips"},
+			},
+		}},
+		SpeakerNames: map[string]string{"SPEAKER_1": "Alice"},
+	}
+
+	vtt, _ := renderVTT(job, formatOptions{})
+	want := "WEBVTT\n\n1\n00:00:01.000 --> 00:00:03.000\n<v Alice>Fish <00:00:01.500>&amp; <00:00:01.800>chips\n\n"
+	if string(vtt) != want {
+		t.Errorf("Expected %q, got %q", want, vtt)
+	}
+}
+
+func TestSnapshotJob(t *testing.T) {
+	job := &Job{
+		ID:              "snapshot-test",
+		SpeakerNames:    map[string]string{"SPEAKER_1": "Alice"},
+		Translations:    map[string]*Translation{"de": {Language: "de", Status: "translating"}},
+		StageRetries:    map[string]int{"download": 1},
+		QualityWarnings: []QualityWarning{{Start: 10, End: 20}},
+	}
+
+	view := snapshotJob(job)
+	job.SpeakerNames["SPEAKER_1"] = "Bob"
+	job.Translations["de"].Status = "done"
+	job.StageRetries["download"] = 2
+	job.QualityWarnings[0].Start = 5
+
+	if view.SpeakerNames["SPEAKER_1"] != "Alice" || view.Translations["de"].Status != "translating" {
+		t.Errorf("Expected the snapshot to be unaffected by later changes, got %+v", view)
+	}
+	if view.StageRetries["download"] != 1 || view.QualityWarnings[0].Start != 10 {
+		t.Errorf("Expected the snapshot to keep its own retries and warnings, got %+v", view)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// artifact is a kind of file a job leaves in the data directory that may be
+// downloaded. Nothing else there, such as job state, is ever served.
+type artifact struct {
+	contentType  string
+	cacheControl string
+}
+
+var artifacts = map[string]artifact{
+	"txt":  {contentType: "text/plain; charset=utf-8", cacheControl: "private, no-cache"},
+	"opus": {contentType: audioFormats["opus"].contentType, cacheControl: "private, max-age=86400"},
+	"mp3":  {contentType: audioFormats["mp3"].contentType, cacheControl: "private, max-age=86400"},
+	"wav":  {contentType: audioFormats["wav"].contentType, cacheControl: "private, max-age=86400"},
+}
+
+// downloadName returns the base of file names for a job's downloads, based
+// on the video title and falling back to the job ID.
+func downloadName(job *Job) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(job.Title) {
+		switch {
+		case strings.ContainsRune(`/\:*?"<>|`, r), unicode.IsControl(r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	title := strings.Trim(b.String(), ". ")
+	if title == "" {
+		title = job.ID
+	}
+	if len(title) > 120 {
+		title = strings.ToValidUTF8(title[:120], "")
+	}
+	return title
+}
+
+// contentDisposition builds the header for a download, encoding non-ASCII
+// names as RFC 2231 requires.
+func contentDisposition(disposition, filename string) string {
+	if v := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return disposition
+}
+
+// handleFiles serves GET /files/{id}.{ext} for the artifacts listed above,
+// with ETag and Last-Modified validation.
+func handleFiles(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, "GET, HEAD, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/files/")
+	id, ext, ok := strings.Cut(name, ".")
+	kind, known := artifacts[ext]
+	if !ok || !known || strings.ContainsAny(id, `/\`) {
+		http.NotFound(w, r)
+		return
+	}
+
+	jobsMu.RLock()
+	job, exists := jobs[id]
+	var filename string
+	if exists {
+		exists = canAccess(r, job)
+		filename = downloadName(job) + "." + ext
+	}
+	jobsMu.RUnlock()
+	// Jobs of other owners look like missing ones
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(cfg.DataDir, id+"."+ext))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", kind.contentType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	w.Header().Set("Cache-Control", kind.cacheControl)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleFiles(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.JobsDir = filepath.Join(c.DataDir, "jobs")
+	cfg = &c
+
+	job := &Job{ID: "files-test", Status: "done", Title: `Talk: "Go" / Ünïcode`}
+	private := &Job{ID: "files-private", Status: "done", Owner: keyFingerprint("team-key")}
+	jobsMu.Lock()
+	jobs[job.ID] = job
+	jobs[private.ID] = private
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, job.ID)
+		delete(jobs, private.ID)
+		jobsMu.Unlock()
+	}()
+
+	os.MkdirAll(c.JobsDir, 0755)
+	os.WriteFile(filepath.Join(c.JobsDir, "files-test.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(c.DataDir, "files-test.txt"), []byte("hello"), 0644)
+	os.WriteFile(filepath.Join(c.DataDir, "files-test.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(c.DataDir, "files-private.txt"), []byte("secret"), 0644)
+
+	for _, path := range []string{
+		"/files/",
+		"/files/jobs/",
+		"/files/jobs/files-test.json",
+		"/files/files-test.json",
+		"/files/files-test.mp3",
+		"/files/unknown.txt",
+		"/files/files-private.txt",
+	} {
+		rec := httptest.NewRecorder()
+		handleFiles(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handleFiles(rec, httptest.NewRequest("GET", "/files/files-test.txt", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("Expected the transcript, got %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+	want := `attachment; filename*=utf-8''Talk_%20_Go_%20_%20%C3%9Cn%C3%AFcode.txt`
+	if cd := rec.Header().Get("Content-Disposition"); cd != want {
+		t.Errorf("Expected %q, got %q", want, cd)
+	}
+	// Exports are named the same way
+	export := httptest.NewRecorder()
+	handleJobRoutes(export, httptest.NewRequest("GET", "/job/files-test/export?format=txt", nil))
+	if cd := export.Header().Get("Content-Disposition"); cd != want {
+		t.Errorf("Expected export named %q, got %q", want, cd)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+	req := httptest.NewRequest("GET", "/files/files-test.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handleFiles(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/files/files-private.txt", nil)
+	req.Header.Set("X-API-Key", "team-key")
+	rec = httptest.NewRecorder()
+	handleFiles(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "secret" {
+		t.Errorf("Expected the owner to get the file, got %d", rec.Code)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename=files-private.txt` {
+		t.Errorf("Expected the job ID as the file name, got %q", cd)
+	}
+}
+
+func TestJobOwnership(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.JobsDir = t.TempDir()
+	cfg = &c
+
+	private := &Job{ID: "owned-test", Status: "done", Text: "secret", Owner: keyFingerprint("team-key"), Created: time.Now()}
+	jobsMu.Lock()
+	jobs[private.ID] = private
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, private.ID)
+		jobsMu.Unlock()
+	}()
+
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/job/owned-test"},
+		{"GET", "/job/owned-test/export?format=txt"},
+		{"GET", "/job/owned-test/transcript"},
+		{"GET", "/job/owned-test/translations"},
+		{"GET", "/job/owned-test/logs"},
+		{"PUT", "/job/owned-test/speakers"},
+		{"DELETE", "/job/owned-test"},
+	} {
+		req := httptest.NewRequest(route.method, route.path, nil)
+		req.Header.Set("X-API-Key", "other-key")
+		rec := httptest.NewRecorder()
+		handleJobRoutes(rec, req)
+		if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "secret") {
+			t.Errorf("%s %s: expected 404 for another key, got %d", route.method, route.path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/job/owned-test", nil)
+	req.Header.Set("Authorization", "Bearer team-key")
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the owner to see the job, got %d", rec.Code)
+	}
+
+	for _, list := range []func(http.ResponseWriter, *http.Request){handleGetActiveJobs, handleGetJobHistory} {
+		rec = httptest.NewRecorder()
+		list(rec, httptest.NewRequest("GET", "/jobs", nil))
+		if strings.Contains(rec.Body.String(), "owned-test") {
+			t.Errorf("Expected lists to hide jobs of other keys, got %s", rec.Body.String())
+		}
+		req = httptest.NewRequest("GET", "/jobs", nil)
+		req.Header.Set("X-API-Key", "team-key")
+		rec = httptest.NewRecorder()
+		list(rec, req)
+		if !strings.Contains(rec.Body.String(), "owned-test") {
+			t.Errorf("Expected the owner's list to include the job, got %s", rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Text renderings of a transcript.
+const (
+	stylePlain       = "plain"       // the transcript as one block
+	styleParagraphs  = "paragraphs"  // paragraphs separated by blank lines
+	styleTimestamped = "timestamped" // paragraphs prefixed with [HH:MM:SS]
+)
+
+// paragraphMaxSentences is the number of sentences after which a paragraph
+// ends at the next sentence boundary even without a pause.
+const paragraphMaxSentences = 5
+
+// formatOptions controls how segments are grouped into paragraphs.
+type formatOptions struct {
+	Style string
+	// A pause of at least Gap seconds after a finished sentence starts a
+	// new paragraph
+	Gap             float64
+	BreakOnChapters bool
+	BreakOnSpeakers bool
+}
+
+func defaultFormatOptions() formatOptions {
+	return formatOptions{
+		Style:           cfg.TextStyle,
+		Gap:             cfg.ParagraphGap,
+		BreakOnChapters: true,
+		BreakOnSpeakers: true,
+	}
+}
+
+// parseFormatOptions reads style and breaks from export query parameters,
+// e.g. ?style=timestamped&breaks=speakers. breaks=none disables both kinds
+// of breaks.
+func parseFormatOptions(q url.Values) (formatOptions, error) {
+	opts := defaultFormatOptions()
+	if style := q.Get("style"); style != "" {
+		if !validTextStyle(style) {
+			return opts, fmt.Errorf("unknown style %q", style)
+		}
+		opts.Style = style
+	}
+	if q.Has("breaks") {
+		opts.BreakOnChapters, opts.BreakOnSpeakers = false, false
+		for _, b := range strings.Split(q.Get("breaks"), ",") {
+			switch strings.TrimSpace(b) {
+			case "chapters":
+				opts.BreakOnChapters = true
+			case "speakers":
+				opts.BreakOnSpeakers = true
+			case "none", "":
+			default:
+				return opts, fmt.Errorf("unknown break %q", b)
+			}
+		}
+	}
+	return opts, nil
+}
+
+func validTextStyle(style string) bool {
+	return style == stylePlain || style == styleParagraphs || style == styleTimestamped
+}
+
+// paragraph is a run of segments rendered as one block of text. Title is set
+// on the first paragraph of a chapter.
+type paragraph struct {
+	Title    string
+	Start    float64
+	Segments []Segment
+}
+
+// buildParagraphs groups segments into paragraphs. A paragraph ends after a
+// finished sentence followed by a pause, after paragraphMaxSentences
+// sentences, at a long pause regardless of punctuation (for transcripts
+// without any), and optionally at chapter boundaries and speaker changes.
+func buildParagraphs(segments []Segment, chapters []Chapter, opts formatOptions) []paragraph {
+	if !opts.BreakOnChapters {
+		chapters = nil
+	}
+
+	var paragraphs []paragraph
+	for _, sec := range splitSections(segments, chapters) {
+		var current *paragraph
+		sentences := 0
+		for i, seg := range sec.Segments {
+			if current != nil {
+				prev := sec.Segments[i-1]
+				gap := seg.Start - prev.End
+				ended := endsSentence(prev.Text)
+				if (opts.BreakOnSpeakers && seg.Speaker != prev.Speaker) ||
+					(ended && (gap >= opts.Gap || sentences >= paragraphMaxSentences)) ||
+					gap >= 3*opts.Gap {
+					current = nil
+				}
+			}
+			if current == nil {
+				paragraphs = append(paragraphs, paragraph{Start: seg.Start})
+				current = &paragraphs[len(paragraphs)-1]
+				if i == 0 {
+					current.Title = sec.Title
+				}
+				sentences = 0
+			}
+			current.Segments = append(current.Segments, seg)
+			sentences += countSentences(seg.Text)
+		}
+	}
+	return paragraphs
+}
+
+// textParagraphs splits plain text into paragraphs of a few sentences, for
+// jobs that have no timed segments.
+func textParagraphs(text string) []string {
+	var paragraphs []string
+	var current strings.Builder
+	sentences := 0
+	for _, word := range strings.Fields(text) {
+		if current.Len() > 0 {
+			current.WriteByte(' ')
+		}
+		current.WriteString(word)
+		if endsSentence(word) {
+			sentences++
+			if sentences >= paragraphMaxSentences {
+				paragraphs = append(paragraphs, current.String())
+				current.Reset()
+				sentences = 0
+			}
+		}
+	}
+	if current.Len() > 0 {
+		paragraphs = append(paragraphs, current.String())
+	}
+	return paragraphs
+}
+
+// endsSentence reports whether text ends with sentence punctuation, ignoring
+// closing quotes and brackets.
+func endsSentence(text string) bool {
+	text = strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`"')]»”’`, r)
+	})
+	if text == "" {
+		return false
+	}
+	r := []rune(text)
+	return strings.ContainsRune(".!?…。！？", r[len(r)-1])
+}
+
+func countSentences(text string) int {
+	n := 0
+	for _, word := range strings.Fields(text) {
+		if endsSentence(word) {
+			n++
+		}
+	}
+	return n
+}
+
+// paragraphText joins the segments of a paragraph, naming the speaker at the
+// start and wherever it changes.
+func paragraphText(job *Job, segments []Segment) string {
+	var b strings.Builder
+	current := ""
+	for i, seg := range segments {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if seg.Speaker != "" && (i == 0 || seg.Speaker != current) {
+			b.WriteString(speakerName(job, seg.Speaker))
+			b.WriteString(": ")
+		}
+		current = seg.Speaker
+		b.WriteString(seg.Text)
+	}
+	return b.String()
+}
+
+// formatText renders a job's transcript as text in the given style.
+func formatText(job *Job, opts formatOptions) string {
+	if opts.Style == stylePlain {
+		return job.Text
+	}
+
+	var b strings.Builder
+	if len(job.Segments) == 0 {
+		for _, p := range textParagraphs(job.Text) {
+			if b.Len() > 0 {
+				b.WriteString("\n\n")
+			}
+			b.WriteString(p)
+		}
+		return b.String()
+	}
+
+	for _, p := range buildParagraphs(job.Segments, jobChapters(job), opts) {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		if p.Title != "" {
+			b.WriteString(p.Title)
+			b.WriteString("\n\n")
+		}
+		if opts.Style == styleTimestamped {
+			fmt.Fprintf(&b, "[%s] ", formatTimestamp(p.Start))
+		}
+		b.WriteString(paragraphText(job, p.Segments))
+	}
+	return b.String()
+}
+
+// transcriptFileText renders the transcript saved next to the audio as
+// {id}.txt, in the configured style.
+func transcriptFileText(job *Job, tr *Transcript) string {
+	jobsMu.RLock()
+	view := &Job{
+		Text:     tr.Text,
+		Segments: tr.Segments,
+		Chapters: job.Chapters,
+		Summary:  job.Summary,
+	}
+	jobsMu.RUnlock()
+	return formatText(view, defaultFormatOptions()) + "\n"
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBuildParagraphs(t *testing.T) {
+	segments := []Segment{
+		{Start: 0, End: 2, Text: "Hello and welcome."},
+		{Start: 2.2, End: 4, Text: "Today we talk about"},
+		{Start: 6, End: 8, Text: "formatting."},
+		{Start: 10, End: 12, Text: "Next part.", Speaker: "SPEAKER_1"},
+		{Start: 12.1, End: 13, Text: "Still going.", Speaker: "SPEAKER_2"},
+	}
+	opts := formatOptions{Style: styleParagraphs, Gap: 1.5, BreakOnSpeakers: true}
+
+	paragraphs := buildParagraphs(segments, nil, opts)
+	// The pause after "about" is mid-sentence, so no break there
+	if len(paragraphs) != 3 {
+		t.Fatalf("Expected 3 paragraphs, got %+v", paragraphs)
+	}
+	if len(paragraphs[0].Segments) != 3 || paragraphs[1].Start != 10 {
+		t.Errorf("Unexpected paragraphs %+v", paragraphs)
+	}
+
+	opts.BreakOnSpeakers = false
+	if got := buildParagraphs(segments, nil, opts); len(got) != 2 {
+		t.Errorf("Expected speaker changes to be ignored, got %d paragraphs", len(got))
+	}
+}
+
+func TestFormatTextStyles(t *testing.T) {
+	job := &Job{
+		Text: "One. Two.",
+		Segments: []Segment{
+			{Start: 754, End: 756, Text: "One."},
+			{Start: 760, End: 762, Text: "Two."},
+		},
+	}
+
+	opts := formatOptions{Style: stylePlain, Gap: 1.5}
+	if got := formatText(job, opts); got != "One. Two." {
+		t.Errorf("Unexpected plain text %q", got)
+	}
+	opts.Style = styleTimestamped
+	if got := formatText(job, opts); got != "[00:12:34] One.\n\n[00:12:40] Two." {
+		t.Errorf("Unexpected timestamped text %q", got)
+	}
+
+	job.Segments = nil
+	job.Text = "A. B. C. D. E. F."
+	opts.Style = styleParagraphs
+	if got := formatText(job, opts); got != "A. B. C. D. E.\n\nF." {
+		t.Errorf("Unexpected paragraphs from plain text %q", got)
+	}
+}
+
+func TestParseFormatOptions(t *testing.T) {
+	opts, err := parseFormatOptions(url.Values{"style": {"timestamped"}, "breaks": {"speakers"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.Style != styleTimestamped || opts.BreakOnChapters || !opts.BreakOnSpeakers {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+	if _, err := parseFormatOptions(url.Values{"style": {"fancy"}}); err == nil {
+		t.Error("Expected unknown style to be rejected")
+	}
+}
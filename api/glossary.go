@@ -0,0 +1,335 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Glossary is custom vocabulary for transcription. Terms and replacement
+// targets are given to whisper as its initial prompt, which nudges it
+// towards the right spelling; replacements then fix what it still gets
+// wrong.
+type Glossary struct {
+	Terms        []string      `json:"terms,omitempty"`
+	Replacements []Replacement `json:"replacements,omitempty"`
+}
+
+// Replacement rewrites From to To after transcription. Matching ignores
+// case unless CaseSensitive is set and only matches whole words unless
+// WholeWord is false. With PreserveCase, an all-caps or capitalized match
+// gets the same treatment in the replacement.
+type Replacement struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	CaseSensitive bool   `json:"case_sensitive,omitempty"`
+	WholeWord     *bool  `json:"whole_word,omitempty"`
+	PreserveCase  bool   `json:"preserve_case,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Substitution records replacements applied to a job's transcript.
+type Substitution struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// glossaryFile is the format of the glossary_file setting. Per-key
+// glossaries extend the global one for jobs submitted with that API key.
+type glossaryFile struct {
+	Global  Glossary            `json:"global"`
+	APIKeys map[string]Glossary `json:"api_keys"`
+}
+
+// glossaries holds the loaded glossary file; per-key entries are indexed by
+// key fingerprint so raw keys are never kept on jobs.
+var glossaries = struct {
+	global Glossary
+	byKey  map[string]Glossary
+}{}
+
+// maxPromptChars keeps the initial prompt within whisper's prompt window of
+// roughly 224 tokens.
+const maxPromptChars = 800
+
+// requestAPIKey returns the API key a client sent in X-API-Key or as a
+// bearer token, or "".
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// keyFingerprint identifies an API key without revealing it.
+func keyFingerprint(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// canAccess reports whether the request may see a job: jobs submitted with an
+// API key only to requests carrying the same key. The caller holds jobsMu.
+func canAccess(r *http.Request, job *Job) bool {
+	return visibleTo(job, keyFingerprint(requestAPIKey(r)))
+}
+
+// visibleTo is canAccess for a key fingerprint computed once per request,
+// for filtering job lists.
+func visibleTo(job *Job, owner string) bool {
+	return job.Owner == "" || job.Owner == owner
+}
+
+func loadGlossaries(path string) error {
+	glossaries.global = Glossary{}
+	glossaries.byKey = nil
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read glossary file: %v", err)
+	}
+	var file glossaryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse glossary file %s: %v", path, err)
+	}
+
+	if err := file.Global.compile(); err != nil {
+		return fmt.Errorf("global glossary: %v", err)
+	}
+	glossaries.global = file.Global
+	glossaries.byKey = make(map[string]Glossary)
+	for key, g := range file.APIKeys {
+		if err := g.compile(); err != nil {
+			return fmt.Errorf("glossary for key %s: %v", keyFingerprint(key), err)
+		}
+		glossaries.byKey[keyFingerprint(key)] = g
+	}
+	return nil
+}
+
+func (g *Glossary) compile() error {
+	for i := range g.Replacements {
+		r := &g.Replacements[i]
+		if strings.TrimSpace(r.From) == "" {
+			return fmt.Errorf("replacement %d has an empty from", i)
+		}
+		// Any run of whitespace in From matches any run in the text
+		expr := strings.Join(strings.Fields(regexp.QuoteMeta(r.From)), `\s+`)
+		if !r.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		var err error
+		if r.pattern, err = regexp.Compile(expr); err != nil {
+			return fmt.Errorf("replacement %q: %v", r.From, err)
+		}
+	}
+	return nil
+}
+
+// glossaryFor returns the global glossary extended with the one for the
+// job's API key.
+func glossaryFor(owner string) Glossary {
+	g := Glossary{
+		Terms:        append([]string(nil), glossaries.global.Terms...),
+		Replacements: append([]Replacement(nil), glossaries.global.Replacements...),
+	}
+	if own, ok := glossaries.byKey[owner]; ok && owner != "" {
+		g.Terms = append(g.Terms, own.Terms...)
+		g.Replacements = append(g.Replacements, own.Replacements...)
+	}
+	return g
+}
+
+// prompt builds whisper's initial prompt from the glossary, or "" when it
+// has no vocabulary.
+func (g Glossary) prompt() string {
+	seen := make(map[string]bool)
+	var words []string
+	add := func(term string) {
+		term = strings.TrimSpace(term)
+		if term != "" && !seen[strings.ToLower(term)] {
+			seen[strings.ToLower(term)] = true
+			words = append(words, term)
+		}
+	}
+	for _, t := range g.Terms {
+		add(t)
+	}
+	for _, r := range g.Replacements {
+		add(r.To)
+	}
+	if len(words) == 0 {
+		return ""
+	}
+
+	prompt := "Glossary: " + words[0]
+	for _, w := range words[1:] {
+		if len(prompt)+len(w)+2 > maxPromptChars {
+			break
+		}
+		prompt += ", " + w
+	}
+	return prompt + "."
+}
+
+// apply runs the replacements over text and adds what it changed to counts.
+func (g Glossary) apply(text string, counts map[[2]string]int) string {
+	for _, r := range g.Replacements {
+		text = r.apply(text, counts)
+	}
+	return text
+}
+
+func (r *Replacement) apply(text string, counts map[[2]string]int) string {
+	wholeWord := r.WholeWord == nil || *r.WholeWord
+	var b strings.Builder
+	last := 0
+	for _, m := range r.pattern.FindAllStringIndex(text, -1) {
+		if wholeWord && !atWordBoundary(text, m[0], m[1]) {
+			continue
+		}
+		match := text[m[0]:m[1]]
+		to := r.To
+		if r.PreserveCase {
+			to = matchCase(match, to)
+		}
+		if match == to {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(to)
+		last = m[1]
+		counts[[2]string{match, to}]++
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// atWordBoundary reports whether text[start:end] is not glued to letters or
+// digits on either side. Unlike \b it understands non-ASCII letters.
+func atWordBoundary(text string, start, end int) bool {
+	if start > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(text[:start]); isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		if r, _ := utf8.DecodeRuneInString(text[end:]); isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// matchCase upper-cases to when match is all caps and capitalizes it when
+// match is capitalized.
+func matchCase(match, to string) string {
+	if strings.ToUpper(match) == match && strings.ToLower(match) != match {
+		return strings.ToUpper(to)
+	}
+	first, _ := utf8.DecodeRuneInString(match)
+	if unicode.IsUpper(first) {
+		r, size := utf8.DecodeRuneInString(to)
+		return string(unicode.ToUpper(r)) + to[size:]
+	}
+	return to
+}
+
+// applyGlossary runs the replacement pass over a transcript and records the
+// substitutions on the job.
+func applyGlossary(job *Job, logger *slog.Logger, tr *Transcript) {
+	jobsMu.RLock()
+	owner := job.Owner
+	jobsMu.RUnlock()
+
+	g := glossaryFor(owner)
+	if len(g.Replacements) == 0 {
+		return
+	}
+
+	counts := make(map[[2]string]int)
+	if len(tr.Segments) > 0 {
+		for i := range tr.Segments {
+			before := tr.Segments[i].Text
+			tr.Segments[i].Text = g.apply(before, counts)
+			// Word timing no longer lines up with a rewritten segment
+			if tr.Segments[i].Text != before {
+				tr.Segments[i].Words = nil
+			}
+		}
+		tr.Text = joinSegmentText(tr.Segments)
+	} else {
+		tr.Text = g.apply(tr.Text, counts)
+	}
+
+	var subs []Substitution
+	total := 0
+	for pair, n := range counts {
+		subs = append(subs, Substitution{From: pair[0], To: pair[1], Count: n})
+		total += n
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Count != subs[j].Count {
+			return subs[i].Count > subs[j].Count
+		}
+		return subs[i].From < subs[j].From
+	})
+
+	jobsMu.Lock()
+	job.Substitutions = subs
+	jobsMu.Unlock()
+	if total > 0 {
+		logger.Info("Applied glossary replacements", "stage", "glossary", "replacements", total)
+	}
+}
+
+// handleGetGlossary shows the glossary that applies to the caller's API key.
+func handleGetGlossary(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, "GET, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(glossaryFor(keyFingerprint(requestAPIKey(r))))
+}
+
+// jobPrompt returns the initial prompt for a job's transcription.
+func jobPrompt(job *Job) string {
+	jobsMu.RLock()
+	owner := job.Owner
+	jobsMu.RUnlock()
+	return glossaryFor(owner).prompt()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGlossaryReplacements(t *testing.T) {
+	off := false
+	g := Glossary{Replacements: []Replacement{
+		{From: "cube ernetes", To: "Kubernetes"},
+		{From: "go", To: "Go", CaseSensitive: true},
+		{From: "vtranscribe", To: "v-transcribe", PreserveCase: true},
+		{From: "colour", To: "color", WholeWord: &off},
+	}}
+	if err := g.compile(); err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+
+	counts := make(map[[2]string]int)
+	got := g.apply("Cube  ernetes and go, not gopher or Go. VTRANSCRIBE watercolours, café go", counts)
+	want := "Kubernetes and Go, not gopher or Go. V-TRANSCRIBE watercolors, café Go"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if counts[[2]string{"go", "Go"}] != 2 || counts[[2]string{"Cube  ernetes", "Kubernetes"}] != 1 {
+		t.Errorf("Unexpected substitution counts %v", counts)
+	}
+}
+
+func TestGlossaryPerAPIKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "glossary.json")
+	content := `{
+		"global": {"terms": ["Prometheus"], "replacements": [{"from": "grafana labs", "to": "Grafana Labs"}]},
+		"api_keys": {"team-key": {"terms": ["Acme Widget"], "replacements": [{"from": "acne", "to": "Acme"}]}}
+	}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadGlossaries(file); err != nil {
+		t.Fatalf("Failed to load glossaries: %v", err)
+	}
+	defer loadGlossaries("")
+
+	owner := keyFingerprint("team-key")
+	if p := glossaryFor(owner).prompt(); p != "Glossary: Prometheus, Acme Widget, Grafana Labs, Acme." {
+		t.Errorf("Unexpected prompt %q", p)
+	}
+	if p := glossaryFor("").prompt(); strings.Contains(p, "Acme") {
+		t.Errorf("Expected other keys' terms to stay private, got %q", p)
+	}
+
+	job := &Job{ID: "glossary-test", Owner: owner}
+	tr := &Transcript{Segments: []Segment{
+		{Text: "Welcome to acne.", Words: []Word{{Word: "Welcome"}}},
+		{Text: "Built with grafana labs tools."},
+	}}
+	applyGlossary(job, slog.Default(), tr)
+	if tr.Text != "Welcome to Acme. Built with Grafana Labs tools." || tr.Segments[0].Words != nil {
+		t.Errorf("Unexpected transcript %+v", tr)
+	}
+	if len(job.Substitutions) != 2 {
+		t.Errorf("Expected 2 recorded substitutions, got %+v", job.Substitutions)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// toolCheckTimeout bounds each external tool probe. whisper has to import its
+// Python dependencies before it answers, so this is generous.
+const toolCheckTimeout = 15 * time.Second
+
+// Tool probes start processes, whisper a whole Python interpreter, so their
+// results are reused for a while instead of re-running on every probe of
+// /readyz. Failures are re-checked sooner so recovery shows up quickly.
+const (
+	toolCheckTTL       = 5 * time.Minute
+	failedToolCheckTTL = 30 * time.Second
+)
+
+var toolChecks = struct {
+	sync.Mutex
+	results map[string]cachedCheck
+}{results: make(map[string]cachedCheck)}
+
+type cachedCheck struct {
+	result  CheckResult
+	expires time.Time
+}
+
+// CheckResult is the outcome of a single readiness check.
+type CheckResult struct {
+	OK        bool   `json:"ok"`
+	Version   string `json:"version,omitempty"`
+	FreeBytes uint64 `json:"free_bytes,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleHealthz reports that the process is up and serving requests.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the server can actually process jobs: the
+// external tools respond and the data directory is writable with enough space.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := runReadinessChecks(r.Context())
+
+	status := "ready"
+	for _, check := range checks {
+		if !check.OK {
+			status = "not_ready"
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+func runReadinessChecks(ctx context.Context) map[string]CheckResult {
+	probes := map[string]func(context.Context) CheckResult{
+		"yt-dlp": func(ctx context.Context) CheckResult {
+			return cachedToolCheck(ctx, "yt-dlp", func(ctx context.Context) CheckResult {
+				return checkTool(ctx, "yt-dlp", "--version")
+			})
+		},
+		"ffmpeg": func(ctx context.Context) CheckResult {
+			return cachedToolCheck(ctx, "ffmpeg", func(ctx context.Context) CheckResult {
+				return checkTool(ctx, "ffmpeg", "-version")
+			})
+		},
+		"whisper": func(ctx context.Context) CheckResult {
+			return cachedToolCheck(ctx, "whisper", checkWhisper)
+		},
+		"data_dir": func(context.Context) CheckResult {
+			return checkDataDir(cfg.DataDir, cfg.MinFreeBytes)
+		},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]CheckResult, len(probes))
+	for name, probe := range probes {
+		wg.Add(1)
+		go func(name string, probe func(context.Context) CheckResult) {
+			defer wg.Done()
+			result := probe(ctx)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, probe)
+	}
+	wg.Wait()
+	return results
+}
+
+// cachedToolCheck returns the cached result of the named probe, running it
+// again once the result has expired. Results of probes cut short by the
+// request going away are not kept.
+func cachedToolCheck(ctx context.Context, name string, probe func(context.Context) CheckResult) CheckResult {
+	toolChecks.Lock()
+	cached, ok := toolChecks.results[name]
+	toolChecks.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.result
+	}
+
+	result := probe(ctx)
+	if ctx.Err() != nil {
+		return result
+	}
+	ttl := toolCheckTTL
+	if !result.OK {
+		ttl = failedToolCheckTTL
+	}
+	toolChecks.Lock()
+	toolChecks.results[name] = cachedCheck{result: result, expires: time.Now().Add(ttl)}
+	toolChecks.Unlock()
+	return result
+}
+
+// checkTool runs a tool's version command and reports the first line of its
+// output as the version.
+func checkTool(ctx context.Context, name string, args ...string) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, toolCheckTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return CheckResult{Error: fmt.Sprintf("%s not responding: %v", name, err)}
+	}
+	return CheckResult{OK: true, Version: firstLine(string(output))}
+}
+
+// checkWhisper verifies the configured whisper executable starts and loads its
+// dependencies. The openai-whisper CLI has no version flag, so the version
+// comes from the installed Python package when it can be determined.
+func checkWhisper(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, toolCheckTimeout)
+	defer cancel()
+
+	if err := exec.CommandContext(ctx, cfg.WhisperBin, "--help").Run(); err != nil {
+		return CheckResult{Error: fmt.Sprintf("%s not responding: %v", cfg.WhisperBin, err)}
+	}
+
+	result := CheckResult{OK: true}
+	output, err := exec.CommandContext(ctx, "python3", "-c",
+		"import importlib.metadata as m; print(m.version('openai-whisper'))").Output()
+	if err == nil {
+		result.Version = firstLine(string(output))
+	}
+	return result
+}
+
+// checkDataDir verifies dir is writable and has at least minFree bytes free.
+func checkDataDir(dir string, minFree uint64) CheckResult {
+	probe, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return CheckResult{Error: fmt.Sprintf("%s is not writable: %v", dir, err)}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, err := freeDiskBytes(dir)
+	if err != nil {
+		return CheckResult{Error: fmt.Sprintf("cannot determine free space in %s: %v", dir, err)}
+	}
+
+	result := CheckResult{OK: true, FreeBytes: free}
+	if free < minFree {
+		result.OK = false
+		result.Error = fmt.Sprintf("only %d bytes free in %s, need %d", free, dir, minFree)
+	}
+	return result
+}
+
+// freeDiskBytes returns the space available to unprivileged users in dir.
+func freeDiskBytes(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
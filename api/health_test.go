@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckDataDir(t *testing.T) {
+	dir := t.TempDir()
+
+	if result := checkDataDir(dir, 0); !result.OK {
+		t.Errorf("Expected writable temp dir to pass, got %+v", result)
+	}
+
+	if result := checkDataDir(dir, ^uint64(0)); result.OK {
+		t.Error("Expected check to fail when free space is below the threshold")
+	}
+
+	if result := checkDataDir(dir+"/missing", 0); result.OK {
+		t.Error("Expected check to fail for a missing directory")
+	}
+}
+
+func TestCheckToolMissing(t *testing.T) {
+	result := checkTool(context.Background(), "v-transcribe-no-such-tool", "--version")
+	if result.OK {
+		t.Error("Expected missing tool to fail the check")
+	}
+}
+
+func TestCachedToolCheck(t *testing.T) {
+	calls := 0
+	probe := func(context.Context) CheckResult {
+		calls++
+		return CheckResult{OK: true, Version: "1.0"}
+	}
+	defer func() {
+		toolChecks.Lock()
+		delete(toolChecks.results, "test-tool")
+		toolChecks.Unlock()
+	}()
+
+	for i := 0; i < 3; i++ {
+		if result := cachedToolCheck(context.Background(), "test-tool", probe); result.Version != "1.0" {
+			t.Fatalf("Unexpected result %+v", result)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the probe to run once, ran %d times", calls)
+	}
+
+	toolChecks.Lock()
+	cached := toolChecks.results["test-tool"]
+	cached.expires = time.Now().Add(-time.Second)
+	toolChecks.results["test-tool"] = cached
+	toolChecks.Unlock()
+	cachedToolCheck(context.Background(), "test-tool", probe)
+	if calls != 2 {
+		t.Errorf("Expected an expired result to be checked again, ran %d times", calls)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleHealthz(rr, httptest.NewRequest("GET", "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 from /healthz, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// orphanMinAge keeps the janitor away from temp files of a job that is only
+// just being set up.
+const orphanMinAge = time.Hour
+
+// janitorReport summarizes one janitor pass.
+type janitorReport struct {
+	AudioRemoved int   `json:"audio_removed"`
+	JobsRemoved  int   `json:"jobs_removed"`
+	TempRemoved  int   `json:"temp_removed"`
+	BytesFreed   int64 `json:"bytes_freed"`
+}
+
+// runJanitor applies the retention settings and removes orphaned temp files
+// every janitor_interval_minutes, starting right away.
+func runJanitor() {
+	ticker := time.NewTicker(time.Duration(cfg.JanitorInterval) * time.Minute)
+	defer ticker.Stop()
+	for {
+		report := janitorPass(time.Now())
+		if report != (janitorReport{}) {
+			slog.Info("Janitor cleaned up", "stage", "janitor", "audio_removed", report.AudioRemoved,
+				"jobs_removed", report.JobsRemoved, "temp_removed", report.TempRemoved, "bytes_freed", report.BytesFreed)
+		}
+		<-ticker.C
+	}
+}
+
+// jobActive reports whether a job is still being worked on. The caller holds
+// jobsMu.
+func jobActive(job *Job) bool {
+	if job.Status != "done" && job.Status != "error" {
+		return true
+	}
+	for _, tr := range job.Translations {
+		if tr.Status != "done" && tr.Status != "error" {
+			return true
+		}
+	}
+	return false
+}
+
+func janitorPass(now time.Time) janitorReport {
+	var report janitorReport
+
+	type candidate struct {
+		id          string
+		created     time.Time
+		expireAudio bool
+		expireJob   bool
+	}
+	var candidates []candidate
+	jobsMu.RLock()
+	for id, job := range jobs {
+		if jobActive(job) || job.ExpiredAt != nil {
+			continue
+		}
+		age := now.Sub(job.Created)
+		// Failed jobs keep their audio, which a manual retry resumes from
+		c := candidate{
+			id:          id,
+			created:     job.Created,
+			expireAudio: job.Status == "done" && cfg.AudioRetentionDays > 0 && age > days(cfg.AudioRetentionDays),
+			expireJob:   cfg.JobRetentionDays > 0 && age > days(cfg.JobRetentionDays),
+		}
+		if c.expireAudio || c.expireJob {
+			candidates = append(candidates, c)
+		}
+	}
+	jobsMu.RUnlock()
+
+	for _, c := range candidates {
+		// The job may have been retried, deleted or replaced since the scan
+		jobsMu.Lock()
+		job, ok := jobs[c.id]
+		if !ok || jobActive(job) || !job.Created.Equal(c.created) {
+			jobsMu.Unlock()
+			continue
+		}
+		if c.expireJob {
+			// Transcripts are kept forever, along with a record that lets
+			// them be listed and served
+			transcript := filepath.Join(cfg.DataDir, c.id+".txt")
+			if _, err := os.Stat(transcript); err != nil {
+				delete(jobs, c.id)
+				jobsMu.Unlock()
+				report.BytesFreed += removeJobFiles(c.id, false)
+				report.JobsRemoved++
+				continue
+			}
+			expired := expiredJob(job, now)
+			jobs[c.id] = expired
+			jobsMu.Unlock()
+			report.BytesFreed += removeJobFiles(c.id, true)
+			saveJobToDisk(expired)
+			report.JobsRemoved++
+			continue
+		}
+		// Removed under the lock so a retry cannot start on the audio meanwhile
+		freed := removeJobAudio(c.id)
+		if freed > 0 {
+			job.AudioFile = ""
+		}
+		jobsMu.Unlock()
+		if freed > 0 {
+			saveJobToDisk(job)
+			report.BytesFreed += freed
+			report.AudioRemoved++
+		}
+	}
+
+	freed, removed := purgeTrash(now)
+	report.BytesFreed += freed
+	report.JobsRemoved += removed
+
+	freed, removed = removeOrphans(now)
+	report.BytesFreed += freed
+	report.TempRemoved += removed
+	return report
+}
+
+// expiredJob returns what is kept of a job past job_retention_days: its
+// metadata and transcript text, without segments, revisions, translations
+// or audio. The caller holds jobsMu.
+func expiredJob(job *Job, now time.Time) *Job {
+	return &Job{
+		ID:                 job.ID,
+		Status:             job.Status,
+		URL:                job.URL,
+		File:               "/files/" + job.ID + ".txt",
+		Text:               job.Text,
+		Progress:           job.Progress,
+		AudioProgress:      job.AudioProgress,
+		TranscriptProgress: job.TranscriptProgress,
+		Created:            job.Created,
+		Title:              job.Title,
+		Thumbnail:          job.Thumbnail,
+		Duration:           job.Duration,
+		ChannelName:        job.ChannelName,
+		Owner:              job.Owner,
+		DetectedLanguage:   job.DetectedLanguage,
+		ExpiredAt:          &now,
+	}
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// removePath deletes a file or directory and returns how many bytes that
+// freed.
+func removePath(path string) int64 {
+	size := pathSize(path)
+	if err := os.RemoveAll(path); err != nil {
+		slog.Warn("Failed to remove file", "stage", "janitor", "path", path, "error", err)
+		return 0
+	}
+	return size
+}
+
+// pathSize returns the size of a file or everything below a directory.
+func pathSize(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// removeJobAudio deletes the playback audio of a job in every format.
+func removeJobAudio(id string) int64 {
+	var freed int64
+	for _, format := range audioFormats {
+		freed += removePath(filepath.Join(cfg.DataDir, id+format.extension))
+	}
+	return freed
+}
+
+// removeJobFiles deletes everything a job keeps on disk: its state file, log,
+// per-job directory with revisions and chunk transcripts, audio and, unless
+// keepTranscript is set, the transcript.
+func removeJobFiles(id string, keepTranscript bool) int64 {
+	freed := removeJobAudio(id)
+	freed += removePath(filepath.Join(cfg.JobsDir, id+".json"))
+	freed += removePath(jobLogPath(id))
+	freed += removePath(filepath.Join(cfg.JobsDir, id))
+	for _, pattern := range []string{id + ".*", id + "_*"} {
+		matches, _ := filepath.Glob(filepath.Join(cfg.TempDir, pattern))
+		for _, m := range matches {
+			freed += removePath(m)
+		}
+	}
+	if !keepTranscript {
+		freed += removePath(filepath.Join(cfg.DataDir, id+".txt"))
+	}
+	return freed
+}
+
+// tempJobID returns the job a temp file belongs to. Only names starting with
+// a job ID count, since the temp directory may be shared.
+func tempJobID(name string) (string, bool) {
+	if len(name) < 36 {
+		return "", false
+	}
+	id := name[:36]
+	if _, err := uuid.Parse(id); err != nil {
+		return "", false
+	}
+	if rest := name[36:]; rest != "" && rest[0] != '.' && rest[0] != '_' {
+		return "", false
+	}
+	return id, true
+}
+
+// removeOrphans deletes temp files (downloaded WAVs, chunks, processed audio,
+// whisper output) and chunk transcripts of jobs that are no longer running.
+func removeOrphans(now time.Time) (int64, int) {
+	var freed int64
+	removed := 0
+
+	entries, _ := os.ReadDir(cfg.TempDir)
+	for _, e := range entries {
+		id, ok := tempJobID(e.Name())
+		if !ok || !orphaned(id) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < orphanMinAge {
+			continue
+		}
+		freed += removePath(filepath.Join(cfg.TempDir, e.Name()))
+		removed++
+	}
+
+	// Chunk transcripts are only kept for failed jobs, which can be retried
+	entries, _ = os.ReadDir(cfg.JobsDir)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		jobsMu.RLock()
+		job, exists := jobs[e.Name()]
+		keep := exists && (jobActive(job) || job.Status == "error")
+		jobsMu.RUnlock()
+		dir := chunkDir(e.Name())
+		if _, err := os.Stat(dir); keep || err != nil {
+			continue
+		}
+		freed += removePath(dir)
+		removed++
+	}
+	return freed, removed
+}
+
+func orphaned(id string) bool {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	job, exists := jobs[id]
+	return !exists || !jobActive(job)
+}
+
+// lowDisk reports whether the data directory is below min_free_bytes, in
+// which case new jobs are refused.
+func lowDisk() bool {
+	free, err := freeDiskBytes(cfg.DataDir)
+	return err == nil && free < cfg.MinFreeBytes
+}
+
+// DiskUsage is the report served by GET /disk.
+type DiskUsage struct {
+	DataDir      string           `json:"data_dir"`
+	FreeBytes    uint64           `json:"free_bytes"`
+	MinFreeBytes uint64           `json:"min_free_bytes"`
+	LowDisk      bool             `json:"low_disk"`
+	Usage        map[string]int64 `json:"usage"`
+	Retention    struct {
+		AudioDays int `json:"audio_days"`
+		JobDays   int `json:"job_days"`
+	} `json:"retention"`
+}
+
+// diskUsage adds up the space taken by audio, transcripts, job state and the
+// pipeline's temp files.
+func diskUsage() DiskUsage {
+	u := DiskUsage{
+		DataDir:      cfg.DataDir,
+		MinFreeBytes: cfg.MinFreeBytes,
+		Usage:        map[string]int64{"audio": 0, "transcripts": 0, "jobs": 0, "temp": 0},
+	}
+	u.FreeBytes, _ = freeDiskBytes(cfg.DataDir)
+	u.LowDisk = u.FreeBytes < cfg.MinFreeBytes
+	u.Retention.AudioDays = cfg.AudioRetentionDays
+	u.Retention.JobDays = cfg.JobRetentionDays
+
+	entries, _ := os.ReadDir(cfg.DataDir)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		switch filepath.Ext(e.Name()) {
+		case ".opus", ".mp3", ".wav":
+			u.Usage["audio"] += info.Size()
+		case ".txt":
+			u.Usage["transcripts"] += info.Size()
+		}
+	}
+	u.Usage["jobs"] = pathSize(cfg.JobsDir)
+
+	entries, _ = os.ReadDir(cfg.TempDir)
+	for _, e := range entries {
+		if _, ok := tempJobID(e.Name()); ok {
+			u.Usage["temp"] += pathSize(filepath.Join(cfg.TempDir, e.Name()))
+		}
+	}
+	return u
+}
+
+// handleDiskUsage serves GET /disk.
+func handleDiskUsage(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, "GET, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	json.NewEncoder(w).Encode(diskUsage())
+}
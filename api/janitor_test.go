@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func janitorTestConfig(t *testing.T) *Config {
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.JobsDir = t.TempDir()
+	c.TempDir = t.TempDir()
+	c.AudioRetentionDays = 7
+	c.JobRetentionDays = 30
+	return &c
+}
+
+func touch(t *testing.T, path string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJanitorRetention(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = janitorTestConfig(t)
+
+	now := time.Now()
+	ids := map[string]*Job{
+		"00000000-0000-0000-0000-000000000001": {Status: "done", Created: now.Add(-10 * 24 * time.Hour), AudioFile: "/job/x/audio"},
+		"00000000-0000-0000-0000-000000000002": {Status: "done", Created: now.Add(-40 * 24 * time.Hour)},
+		"00000000-0000-0000-0000-000000000003": {Status: "transcribing", Created: now.Add(-40 * 24 * time.Hour)},
+		"00000000-0000-0000-0000-000000000004": {Status: "error", Created: now.Add(-10 * 24 * time.Hour), AudioFile: "/job/x/audio"},
+	}
+	jobsMu.Lock()
+	for id, job := range ids {
+		job.ID = id
+		jobs[id] = job
+		touch(t, filepath.Join(cfg.DataDir, id+".opus"), now)
+		touch(t, filepath.Join(cfg.DataDir, id+".txt"), now)
+	}
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		for id := range ids {
+			delete(jobs, id)
+		}
+		jobsMu.Unlock()
+	}()
+
+	report := janitorPass(now)
+	if report.AudioRemoved != 1 || report.JobsRemoved != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(cfg.DataDir, name))
+		return err == nil
+	}
+	if exists("00000000-0000-0000-0000-000000000001.opus") || ids["00000000-0000-0000-0000-000000000001"].AudioFile != "" {
+		t.Error("Expected the audio of the week-old job to be removed")
+	}
+	jobsMu.RLock()
+	expired := jobs["00000000-0000-0000-0000-000000000002"]
+	jobsMu.RUnlock()
+	if expired == nil || expired.ExpiredAt == nil || !exists("00000000-0000-0000-0000-000000000002.txt") {
+		t.Fatal("Expected the old job to be reduced to a record with its transcript kept")
+	}
+	if _, err := os.Stat(filepath.Join(cfg.JobsDir, expired.ID+".json")); err != nil {
+		t.Errorf("Expected the reduced record to be saved: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	handleFiles(rec, httptest.NewRequest("GET", "/files/00000000-0000-0000-0000-000000000002.txt", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the kept transcript to be served, got %d", rec.Code)
+	}
+	if report := janitorPass(now); report.JobsRemoved != 0 {
+		t.Errorf("Expected an expired job to be left alone afterwards, got %+v", report)
+	}
+	if !exists("00000000-0000-0000-0000-000000000003.opus") {
+		t.Error("Expected the running job to be left alone")
+	}
+	if !exists("00000000-0000-0000-0000-000000000004.opus") || ids["00000000-0000-0000-0000-000000000004"].AudioFile == "" {
+		t.Error("Expected the failed job to keep its audio for a retry")
+	}
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = janitorTestConfig(t)
+
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+	active := "00000000-0000-0000-0000-00000000000a"
+	jobsMu.Lock()
+	jobs[active] = &Job{ID: active, Status: "transcribing"}
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, active)
+		jobsMu.Unlock()
+	}()
+
+	files := map[string]bool{
+		"00000000-0000-0000-0000-00000000000b.wav":         true,
+		"00000000-0000-0000-0000-00000000000b_chunk_3.wav": true,
+		active + ".wav":                         false,
+		"unrelated-file.wav":                    false,
+		"00000000-0000-0000-0000-00000000000bx": false,
+	}
+	for name := range files {
+		touch(t, filepath.Join(cfg.TempDir, name), old)
+	}
+	touch(t, filepath.Join(cfg.TempDir, "00000000-0000-0000-0000-00000000000c.wav"), now)
+
+	if _, removed := removeOrphans(now); removed != 2 {
+		t.Errorf("Expected 2 orphans removed, got %d", removed)
+	}
+	for name, orphan := range files {
+		_, err := os.Stat(filepath.Join(cfg.TempDir, name))
+		if orphan != os.IsNotExist(err) {
+			t.Errorf("%s: expected removed=%v", name, orphan)
+		}
+	}
+}
+
+func TestLowDiskRefusesJobs(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = janitorTestConfig(t)
+	cfg.MinFreeBytes = ^uint64(0)
+
+	rec := httptest.NewRecorder()
+	handleJob(rec, httptest.NewRequest("POST", "/job", strings.NewReader(`{"url": "https://www.youtube.com/watch?v=dQw4w9WgXcQ"}`)))
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("Expected 507, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handleDiskUsage(rec, httptest.NewRequest("GET", "/disk", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"low_disk":true`) {
+		t.Errorf("Expected low disk in the report, got %d %s", rec.Code, rec.Body.String())
+	}
+}
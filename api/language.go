@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// LanguageDetection is the language whisper detected in one chunk of a
+// chunked transcription. Words weighs the chunk when picking the majority.
+type LanguageDetection struct {
+	Chunk       int     `json:"chunk"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Language    string  `json:"language"`
+	Probability float64 `json:"probability,omitempty"`
+	Words       int     `json:"words"`
+	// Set when the chunk was transcribed again in the majority language
+	Forced bool `json:"forced,omitempty"`
+}
+
+// addChunkLanguage records the language of the index-th chunk.
+func (tr *Transcript) addChunkLanguage(index int, part *Transcript) {
+	if part.Language == "" {
+		return
+	}
+	start := float64(index * cfg.ChunkSeconds)
+	tr.Languages = append(tr.Languages, LanguageDetection{
+		Chunk:       index,
+		Start:       start,
+		End:         start + float64(cfg.ChunkSeconds),
+		Language:    part.Language,
+		Probability: part.LanguageProbability,
+		Words:       len(strings.Fields(part.Text)),
+	})
+}
+
+// majorityLanguage returns the language covering the most words and its
+// word-weighted mean probability. Ties go to the language seen first.
+func majorityLanguage(detections []LanguageDetection) (string, float64) {
+	words := make(map[string]int)
+	weighted := make(map[string]float64)
+	var order []string
+	for _, d := range detections {
+		if _, ok := words[d.Language]; !ok {
+			order = append(order, d.Language)
+		}
+		// Chunks without words still count, so silence-only jobs get a language
+		n := max(d.Words, 1)
+		words[d.Language] += n
+		weighted[d.Language] += d.Probability * float64(n)
+	}
+
+	best := ""
+	for _, lang := range order {
+		if best == "" || words[lang] > words[best] {
+			best = lang
+		}
+	}
+	if best == "" {
+		return "", 0
+	}
+	return best, weighted[best] / float64(words[best])
+}
+
+// jobLanguage returns the detected spoken language of a job, falling back to
+// the language from the video metadata.
+func jobLanguage(job *Job) string {
+	if job.DetectedLanguage != "" {
+		return job.DetectedLanguage
+	}
+	return job.Language
+}
+
+// detectLanguage records the language of the transcript on the job. Chunks
+// that disagree with the majority get a quality warning and, with
+// force_language, are transcribed again in the majority language.
+func detectLanguage(job *Job, logger *slog.Logger, audioFile string, tr *Transcript) {
+	logger = logger.With("stage", "language")
+
+	language, probability := tr.Language, tr.LanguageProbability
+	detections := tr.Languages
+	var warnings []QualityWarning
+	if len(detections) > 0 {
+		language, probability = majorityLanguage(detections)
+
+		var mismatched []int
+		for i, d := range detections {
+			if d.Language != language {
+				mismatched = append(mismatched, i)
+			}
+		}
+		if len(mismatched) > 0 {
+			logger.Warn("Chunks disagree on the spoken language", "language", language, "mismatched_chunks", len(mismatched))
+		}
+		if len(mismatched) > 0 && cfg.ForceLanguage {
+			defer metrics.observeStage("language", time.Now())
+			updateJobStatusDetailed(job, "retranscribing", 78, 100, 90, "")
+			opts := whisperOptions{model: cfg.Model, language: language, prompt: jobPrompt(job)}
+			for _, i := range mismatched {
+				detections[i].Forced = forceChunkLanguage(job, logger, audioFile, opts, detections[i], tr)
+			}
+		}
+
+		for _, i := range mismatched {
+			d := detections[i]
+			warnings = append(warnings, QualityWarning{
+				Kind:     warnLanguage,
+				Start:    d.Start,
+				End:      d.End,
+				Detail:   fmt.Sprintf("chunk %d detected as %s, majority is %s", d.Chunk+1, d.Language, language),
+				Rerun:    d.Forced,
+				Resolved: d.Forced,
+			})
+		}
+	}
+
+	jobsMu.Lock()
+	job.DetectedLanguage = language
+	job.LanguageProbability = probability
+	job.ChunkLanguages = detections
+	// This is the first stage to record warnings, so older ones are stale
+	job.QualityWarnings = warnings
+	jobsMu.Unlock()
+
+	if language != "" {
+		logger.Info("Detected spoken language", "language", language, "probability", probability)
+	}
+}
+
+// forceChunkLanguage transcribes one chunk again with its language forced
+// and swaps it into tr. It reports whether that succeeded.
+func forceChunkLanguage(job *Job, logger *slog.Logger, audioFile string, opts whisperOptions, d LanguageDetection, tr *Transcript) bool {
+	logger = logger.With("chunk", d.Chunk+1)
+
+	chunk, err := splitAudioChunk(audioFile, d.Chunk)
+	if err != nil {
+		logger.Warn("Failed to extract audio for re-transcription", "error", err)
+		return false
+	}
+	defer os.Remove(chunk)
+
+	part, err := transcribeAudioDirect(logger, chunk, opts)
+	if err != nil {
+		logger.Warn("Re-transcription in the majority language failed", "error", err)
+		return false
+	}
+	part.shift(d.Start)
+
+	// Keep a resumed job from detecting the old language again
+	if err := saveChunkTranscript(chunkDir(job.ID), d.Chunk, part); err != nil {
+		logger.Warn("Failed to persist chunk transcript", "error", err)
+	}
+
+	replaceWindow(tr, qualityWindow{Index: d.Chunk, Start: d.Start, End: d.End}, part.Segments)
+	logger.Info("Re-transcribed chunk in the majority language", "language", opts.language)
+	return true
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+)
+
+func TestMajorityLanguage(t *testing.T) {
+	detections := []LanguageDetection{
+		{Chunk: 0, Language: "de", Probability: 0.5, Words: 10},
+		{Chunk: 1, Language: "en", Probability: 0.9, Words: 200},
+		{Chunk: 2, Language: "en", Probability: 0.6, Words: 100},
+	}
+	lang, prob := majorityLanguage(detections)
+	if lang != "en" || prob < 0.79 || prob > 0.81 {
+		t.Errorf("Expected en with probability 0.8, got %s %.2f", lang, prob)
+	}
+	if lang, _ := majorityLanguage(nil); lang != "" {
+		t.Errorf("Expected no language, got %q", lang)
+	}
+}
+
+func TestDetectLanguageWarnsOnMixedChunks(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.ChunkSeconds = 60
+	c.ForceLanguage = false
+	cfg = &c
+
+	tr := &Transcript{}
+	for i, part := range []*Transcript{
+		{Text: "Hello and welcome to the show.", Language: "en"},
+		{Text: "Guten Tag.", Language: "de"},
+		{Text: "Back to English now.", Language: "en"},
+	} {
+		tr.append(part)
+		tr.addChunkLanguage(i, part)
+	}
+
+	job := &Job{ID: "language-test", Language: "en-US", QualityWarnings: []QualityWarning{{Kind: warnEmpty}}}
+	detectLanguage(job, slog.Default(), "", tr)
+
+	if job.DetectedLanguage != "en" || jobLanguage(job) != "en" || len(job.ChunkLanguages) != 3 {
+		t.Errorf("Unexpected detection %q %+v", job.DetectedLanguage, job.ChunkLanguages)
+	}
+	if len(job.QualityWarnings) != 1 {
+		t.Fatalf("Expected only the language warning, got %+v", job.QualityWarnings)
+	}
+	if w := job.QualityWarnings[0]; w.Kind != warnLanguage || w.Start != 60 || w.End != 120 || w.Rerun {
+		t.Errorf("Unexpected warning %+v", w)
+	}
+}
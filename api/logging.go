@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// setupLogging installs a JSON slog handler on stderr as the default logger.
+func setupLogging(level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %v", level, err)
+	}
+	logLevel.Set(lvl)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+	return nil
+}
+
+// logLevel is shared by the default handler and the per-job log files.
+var logLevel = new(slog.LevelVar)
+
+// jobLogPath returns the path of a job's own log file.
+func jobLogPath(jobID string) string {
+	return filepath.Join(cfg.JobsDir, jobID+".log")
+}
+
+// jobLogger returns a logger tagged with the job ID that writes both to the
+// default logger and to the job's log file.
+func jobLogger(jobID string) *slog.Logger {
+	file := slog.NewJSONHandler(&appendFileWriter{path: jobLogPath(jobID)}, &slog.HandlerOptions{Level: logLevel})
+	return slog.New(fanoutHandler{slog.Default().Handler(), file}).With("job_id", jobID)
+}
+
+// appendFileWriter appends every write to a file, opening it each time so no
+// descriptor has to be tracked for the lifetime of a job. slog handlers write
+// one record per call, so records are never interleaved.
+type appendFileWriter struct {
+	path string
+}
+
+func (w *appendFileWriter) Write(p []byte) (int, error) {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.Write(p)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// fanoutHandler sends every record to all of its handlers.
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) {
+			errs = append(errs, handler.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		out[i] = handler.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		out[i] = handler.WithGroup(name)
+	}
+	return out
+}
+
+// handleGetJobLogs serves a job's log file as newline-delimited JSON.
+func handleGetJobLogs(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobsMu.RLock()
+	_, exists := jobs[id]
+	jobsMu.RUnlock()
+	if !exists || strings.ContainsAny(id, `/\`) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := os.ReadFile(jobLogPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "No logs for job", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to read logs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Write(data)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJobLoggerWritesJobLogFile(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = defaultConfig()
+	cfg.DataDir = t.TempDir()
+	cfg.fillDerived()
+	if err := os.MkdirAll(cfg.JobsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	jobLogger("log-test").Info("Processing chunk", "stage", "transcribe", "chunk", 3)
+
+	data, err := os.ReadFile(jobLogPath("log-test"))
+	if err != nil {
+		t.Fatalf("Expected job log file to be written: %v", err)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &record); err != nil {
+		t.Fatalf("Expected a JSON log record, got %q: %v", data, err)
+	}
+	if record["job_id"] != "log-test" || record["stage"] != "transcribe" || record["chunk"] != float64(3) {
+		t.Errorf("Unexpected log record attributes: %v", record)
+	}
+
+	jobsMu.Lock()
+	jobs["log-test"] = &Job{ID: "log-test"}
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, "log-test")
+		jobsMu.Unlock()
+	}()
+
+	rr := httptest.NewRecorder()
+	handleJobRoutes(rr, httptest.NewRequest("GET", "/job/log-test/logs", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from logs endpoint, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"msg":"Processing chunk"`) {
+		t.Errorf("Expected log line in response, got %q", rr.Body.String())
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +29,8 @@ type Job struct {
 	AudioProgress  int       `json:"audio_progress"`
 	TranscriptProgress int   `json:"transcript_progress"`
 	Error          string    `json:"error,omitempty"`
+	ErrorCode      errorCode `json:"error_code,omitempty"`
+	ErrorOutput    string    `json:"error_output,omitempty"`
 	Created        time.Time `json:"created"`
 	
 	// Video metadata
@@ -34,35 +39,137 @@ type Job struct {
 	Thumbnail      string    `json:"thumbnail,omitempty"`
 	Duration       int       `json:"duration,omitempty"`
 	ChannelName    string    `json:"channel_name,omitempty"`
+	UploadDate     string    `json:"upload_date,omitempty"`
+	Tags           []string  `json:"tags,omitempty"`
+	Language       string    `json:"language,omitempty"`
+	ViewCount      int64     `json:"view_count,omitempty"`
+	WebpageURL     string    `json:"webpage_url,omitempty"`
+	Chapters       []Chapter `json:"chapters,omitempty"`
+	
+	// Audio pre-processing preset applied before transcription
+	Preset         string `json:"preset,omitempty"`
+	
+	// Timed segments, labelled with speakers when the job was diarized
+	Segments       []Segment         `json:"segments,omitempty"`
+	Diarize        bool              `json:"diarize,omitempty"`
+	SpeakerNames   map[string]string `json:"speaker_names,omitempty"`
+	
+	// Transcript edits; Text and Segments hold the latest revision
+	Revisions      []RevisionInfo `json:"revisions,omitempty"`
+	
+	// Translations by language code
+	Translations   map[string]*Translation `json:"translations,omitempty"`
+	
+	// LLM summary, key points and chapters when requested
+	Summarize      bool     `json:"summarize,omitempty"`
+	Summary        *Summary `json:"summary,omitempty"`
+	
+	// Fingerprint of the API key the job was submitted with
+	Owner          string         `json:"owner,omitempty"`
+	// Glossary replacements applied to the transcript
+	Substitutions  []Substitution `json:"substitutions,omitempty"`
+	
+	// PII redaction of the transcript and, with BleepAudio, the served audio
+	Redact         bool             `json:"redact,omitempty"`
+	BleepAudio     bool             `json:"bleep_audio,omitempty"`
+	Redactions     *RedactionReport `json:"redactions,omitempty"`
+	
+	// Likely transcription problems found by the quality check
+	QualityWarnings []QualityWarning `json:"quality_warnings,omitempty"`
+	
+	// Spoken language detected by whisper, as opposed to the Language
+	// yt-dlp reports for the video
+	DetectedLanguage    string              `json:"detected_language,omitempty"`
+	LanguageProbability float64             `json:"language_probability,omitempty"`
+	ChunkLanguages      []LanguageDetection `json:"chunk_languages,omitempty"`
+	
+	// Set while the job is in the trash
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	// Set once retention has reduced the job to its transcript
+	ExpiredAt      *time.Time `json:"expired_at,omitempty"`
+	
+	// Retry bookkeeping
+	RetryCount     int          `json:"retry_count,omitempty"`
+	Attempts       []JobAttempt `json:"attempts,omitempty"`
+	
+	// Automatic retries used per stage, and when a re-queued job runs again
+	StageRetries   map[string]int `json:"stage_retries,omitempty"`
+	NextRetry      *time.Time     `json:"next_retry,omitempty"`
 }
 
 var (
 	jobs   = make(map[string]*Job)
 	jobsMu sync.RWMutex
 	
-	jobQueue = make(chan string, 100)
+	jobQueue = make(chan queuedJob, 100)
 )
 
+// queuedJob is a unit of work for the background worker. Resume marks jobs
+// recovered from disk after a restart, which take the resume path instead of
+// starting from scratch.
+type queuedJob struct {
+	ID     string
+	Resume bool
+}
+
 func main() {
-	if err := os.MkdirAll("/data", 0755); err != nil {
-		log.Printf("Warning: Could not create /data directory: %v", err)
+	loaded, err := loadConfig(os.Args[1:])
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	cfg = loaded
+	if err := setupLogging(cfg.LogLevel); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	
+	diarizer, err = newDiarizer(cfg)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	translator, err = newTranslator(cfg)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	summarizer, err = newSummarizer(cfg)
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if err := loadGlossaries(cfg.GlossaryFile); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
 	}
 	
-	if err := os.MkdirAll("/data/jobs", 0755); err != nil {
-		log.Printf("Warning: Could not create /data/jobs directory: %v", err)
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		slog.Warn("Could not create data directory", "dir", cfg.DataDir, "error", err)
 	}
 	
-	loadJobsFromDisk()
+	if err := os.MkdirAll(cfg.JobsDir, 0755); err != nil {
+		slog.Warn("Could not create jobs directory", "dir", cfg.JobsDir, "error", err)
+	}
+	
+	recovered := loadJobsFromDisk()
 	go backgroundWorker()
+	go runJanitor()
+	go enqueueRecoveredJobs(recovered)
 	http.HandleFunc("/job", handleJob)
-	http.HandleFunc("/job/", handleGetJob)
+	http.HandleFunc("/job/", handleJobRoutes)
 	http.HandleFunc("/jobs/active", handleGetActiveJobs)
 	http.HandleFunc("/jobs/history", handleGetJobHistory)
-	http.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir("/data"))))
+	http.HandleFunc("/jobs/trash", handleGetTrash)
+	http.HandleFunc("/config", handleGetConfig)
+	http.HandleFunc("/glossary", handleGetGlossary)
+	http.HandleFunc("/metrics", handleMetrics)
+	http.HandleFunc("/disk", handleDiskUsage)
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz)
+	http.HandleFunc("/files/", handleFiles)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		setCORSHeaders(w, "GET, POST, OPTIONS")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -71,14 +178,23 @@ func main() {
 		http.NotFound(w, r)
 	})
 
-	log.Println("Server starting on :8081")
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	slog.Info("Server starting", "addr", cfg.Addr)
+	if err := http.ListenAndServe(cfg.Addr, nil); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
+	}
 }
 
-func handleJob(w http.ResponseWriter, r *http.Request) {
+// setCORSHeaders lets browsers on other origins call a handler with the given
+// methods, including requests that carry an API key.
+func setCORSHeaders(w http.ResponseWriter, methods string) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", methods)
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, Authorization")
+}
+
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, "GET, POST, OPTIONS")
 
 	if r.Method == "OPTIONS" {
 		return
@@ -90,7 +206,14 @@ func handleJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var payload struct {
-		URL string `json:"url"`
+		URL       string `json:"url"`
+		Diarize   bool   `json:"diarize"`
+		Summarize bool   `json:"summarize"`
+		Redact    bool   `json:"redact"`
+		// Bleeping implies redaction
+		BleepAudio bool `json:"bleep_audio"`
+		// Audio pre-processing preset, the server default when empty
+		Preset string `json:"preset"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -108,13 +231,42 @@ func handleJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if lowDisk() {
+		http.Error(w, "Not enough free disk space to accept new jobs", http.StatusInsufficientStorage)
+		return
+	}
+
+	if payload.Preset == "" {
+		payload.Preset = cfg.AudioPreset
+	}
+	if _, err := cfg.presetFilters(payload.Preset); payload.Preset != "" && err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Diarize && diarizer == nil {
+		http.Error(w, "Diarization is not configured on this server", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Summarize && summarizer == nil {
+		http.Error(w, "Summarization is not configured on this server", http.StatusBadRequest)
+		return
+	}
+
 	id := uuid.NewString()
 	job := &Job{
-		ID:       id,
-		Status:   "queued",
-		URL:      payload.URL,
-		Progress: 0,
-		Created:  time.Now(),
+		ID:         id,
+		Status:     "queued",
+		URL:        payload.URL,
+		Progress:   0,
+		Created:    time.Now(),
+		Diarize:    payload.Diarize,
+		Summarize:  payload.Summarize,
+		Owner:      keyFingerprint(requestAPIKey(r)),
+		Redact:     payload.Redact || payload.BleepAudio,
+		BleepAudio: payload.BleepAudio,
+		Preset:     payload.Preset,
 	}
 
 	jobsMu.Lock()
@@ -122,19 +274,79 @@ func handleJob(w http.ResponseWriter, r *http.Request) {
 	jobsMu.Unlock()
 
 	select {
-	case jobQueue <- id:
-		log.Printf("Job %s queued for background processing", id)
+	case jobQueue <- queuedJob{ID: id}:
+		jobLogger(id).Info("Job queued for background processing", "stage", "queue")
 	default:
-		log.Printf("Job queue full, processing job %s immediately", id)
-		go processJob(job, payload.URL)
+		jobLogger(id).Warn("Job queue full, processing job immediately", "stage", "queue")
+		go func() {
+			metrics.workerStarted()
+			defer metrics.workerFinished()
+			processJob(job, payload.URL)
+		}()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	jobsMu.RLock()
+	view := snapshotJob(job)
+	jobsMu.RUnlock()
+	json.NewEncoder(w).Encode(view)
+}
+
+// handleJobRoutes dispatches /job/{id} and its sub-resources
+func handleJobRoutes(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/job/")
+	id, action, _ := strings.Cut(rest, "/")
+
+	// Jobs of other API keys look like missing ones on every sub-route
+	if r.Method != "OPTIONS" && !jobAccessible(r, id) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method == "DELETE" || r.Method == "OPTIONS" {
+			handleDeleteJob(w, r, id)
+			return
+		}
+		handleGetJob(w, r)
+	case "restore":
+		handleRestoreJob(w, r, id)
+	case "retry":
+		handleRetryJob(w, r, id)
+	case "logs":
+		handleGetJobLogs(w, r, id)
+	case "audio":
+		handleAudio(w, r, id)
+	case "export":
+		handleExport(w, r, id)
+	case "speakers":
+		handleRenameSpeakers(w, r, id)
+	case "translations":
+		handleTranslations(w, r, id)
+	case "transcript", "transcript/revisions", "transcript/diff":
+		_, sub, _ := strings.Cut(action, "/")
+		handleTranscript(w, r, id, sub)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// jobAccessible reports whether the request may see the job with id, live or
+// in the trash. Unknown IDs pass so the handlers answer them as usual.
+func jobAccessible(r *http.Request, id string) bool {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	job, exists := jobs[id]
+	if !exists {
+		job, exists = trash[id]
+	}
+	return !exists || canAccess(r, job)
 }
 
 func handleGetJob(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	setCORSHeaders(w, "GET, DELETE, OPTIONS")
 	w.Header().Set("Content-Type", "application/json")
 
 	id := strings.TrimPrefix(r.URL.Path, "/job/")
@@ -145,6 +357,9 @@ func handleGetJob(w http.ResponseWriter, r *http.Request) {
 
 	jobsMu.RLock()
 	job, exists := jobs[id]
+	if exists {
+		job = snapshotJob(job)
+	}
 	jobsMu.RUnlock()
 
 	if !exists {
@@ -155,25 +370,147 @@ func handleGetJob(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(job)
 }
 
+// handleRetryJob re-queues a failed job. It goes through the resume path, so
+// audio that was already downloaded is reused.
+func handleRetryJob(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, POST, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobsMu.Lock()
+	job, exists := jobs[id]
+	if !exists {
+		jobsMu.Unlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.Status != "error" {
+		jobsMu.Unlock()
+		http.Error(w, "Only failed jobs can be retried", http.StatusConflict)
+		return
+	}
+
+	select {
+	case jobQueue <- queuedJob{ID: id, Resume: true}:
+	default:
+		jobsMu.Unlock()
+		http.Error(w, "Job queue full", http.StatusServiceUnavailable)
+		return
+	}
+
+	job.Status = "queued"
+	job.Error = ""
+	job.ErrorCode = ""
+	job.ErrorOutput = ""
+	job.Progress = 0
+	job.StageRetries = nil
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+
+	jobLogger(id).Info("Job queued for manual retry", "stage", "queue")
+
+	w.Header().Set("Content-Type", "application/json")
+	jobsMu.RLock()
+	json.NewEncoder(w).Encode(job)
+	jobsMu.RUnlock()
+}
+
+// handleRenameSpeakers sets display names for a job's speaker labels, e.g.
+// {"SPEAKER_1": "Alice"}. An empty name restores the label.
+func handleRenameSpeakers(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, PUT, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var names map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	jobsMu.Lock()
+	job, exists := jobs[id]
+	if !exists {
+		jobsMu.Unlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, seg := range job.Segments {
+		if seg.Speaker != "" {
+			known[seg.Speaker] = true
+		}
+	}
+	for label := range names {
+		if !known[label] {
+			jobsMu.Unlock()
+			http.Error(w, fmt.Sprintf("Unknown speaker %q", label), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if job.SpeakerNames == nil {
+		job.SpeakerNames = make(map[string]string)
+	}
+	for label, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			delete(job.SpeakerNames, label)
+		} else {
+			job.SpeakerNames[label] = name
+		}
+	}
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	jobsMu.RLock()
+	json.NewEncoder(w).Encode(job.SpeakerNames)
+	jobsMu.RUnlock()
+}
+
 func handleGetActiveJobs(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	setCORSHeaders(w, "GET, OPTIONS")
 	w.Header().Set("Content-Type", "application/json")
 
+	if r.Method == "OPTIONS" {
+		return
+	}
+
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	owner := keyFingerprint(requestAPIKey(r))
 	jobsMu.RLock()
 	activeJobs := make([]*Job, 0)
 	for _, job := range jobs {
+		if !visibleTo(job, owner) {
+			continue
+		}
 		// Return jobs that are active or recently completed (last 24 hours)
 		if job.Status != "done" && job.Status != "error" {
-			activeJobs = append(activeJobs, job)
+			activeJobs = append(activeJobs, snapshotJob(job))
 		} else {
 			// Check if completed recently
 			if time.Since(job.Created).Hours() < 24 {
-				activeJobs = append(activeJobs, job)
+				activeJobs = append(activeJobs, snapshotJob(job))
 			}
 		}
 	}
@@ -183,20 +520,31 @@ func handleGetActiveJobs(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleGetJobHistory(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	setCORSHeaders(w, "GET, OPTIONS")
 	w.Header().Set("Content-Type", "application/json")
 
+	if r.Method == "OPTIONS" {
+		return
+	}
+
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// ?language=de lists only jobs in that spoken language
+	language := r.URL.Query().Get("language")
+
+	owner := keyFingerprint(requestAPIKey(r))
 	jobsMu.RLock()
 	historyJobs := make([]*Job, 0)
 	for _, job := range jobs {
+		if !visibleTo(job, owner) {
+			continue
+		}
 		// Return only completed jobs sorted by creation date (newest first)
-		if job.Status == "done" {
-			historyJobs = append(historyJobs, job)
+		if job.Status == "done" && (language == "" || jobLanguage(job) == language) {
+			historyJobs = append(historyJobs, snapshotJob(job))
 		}
 	}
 	jobsMu.RUnlock()
@@ -214,124 +562,196 @@ func handleGetJobHistory(w http.ResponseWriter, r *http.Request) {
 }
 
 func processJob(job *Job, url string) {
+	logger := jobLogger(job.ID)
 	defer func() {
 		if r := recover(); r != nil {
-			updateJobStatusDetailed(job, "error", 0, 0, 0, fmt.Sprintf("Internal error: %v", r))
+			failJob(job, 0, "Internal error", fmt.Errorf("%v", r))
 		}
 	}()
 
 	// Step 0: Extract video metadata
 	updateJobStatusDetailed(job, "fetching_info", 10, 0, 0, "")
 	if err := extractVideoMetadata(job, url); err != nil {
-		log.Printf("Warning: Failed to extract video metadata: %v", err)
+		logger.Warn("Failed to extract video metadata", "stage", "metadata", "error", err)
 		// Continue processing even if metadata extraction fails
 	}
 
 	// Step 1: Download audio
 	updateJobStatusDetailed(job, "downloading", 25, 0, 0, "")
-	audioFile, err := downloadAudio(job.ID, url)
+	var audioFile string
+	err := withRetry(job, logger.With("stage", "download"), "download", func() error {
+		var err error
+		audioFile, err = downloadAudio(job.ID, url)
+		return err
+	})
 	if err != nil {
-		updateJobStatusDetailed(job, "error", 0, 0, 0, fmt.Sprintf("Download failed: %v", err))
+		if scheduleRetry(job, logger, err) {
+			return
+		}
+		failJob(job, 0, "Download failed", err)
 		return
 	}
 	
 	// Copy audio to data directory for serving
 	audioFilename := fmt.Sprintf("%s.wav", job.ID)
-	audioPath := filepath.Join("/data", audioFilename)
+	audioPath := filepath.Join(cfg.DataDir, audioFilename)
 	if err := copyFile(audioFile, audioPath); err != nil {
-		log.Printf("Warning: Failed to copy audio file for serving: %v", err)
+		logger.Warn("Failed to copy audio file for serving", "stage", "download", "error", err)
 	} else {
 		jobsMu.Lock()
-		job.AudioFile = "/files/" + audioFilename
+		job.AudioFile = audioURL(job.ID)
 		jobsMu.Unlock()
-		log.Printf("Audio file available for download: /files/%s", audioFilename)
+		logger.Info("Audio file available for download", "stage", "download", "file", audioURL(job.ID))
 	}
 	
+	// Filters and silence trimming apply to a copy used for transcription
+	prepared := preprocessAudio(job, logger, audioFile)
+	defer prepared.remove(audioFile)
+	
 	// Audio download complete
 	updateJobStatusDetailed(job, "transcribing", 50, 100, 0, "")
 
 	// Step 2: Transcribe
-	transcript, err := transcribeAudio(audioFile)
+	transcript, err := transcribeAudio(job, prepared.path)
 	if err != nil {
-		updateJobStatusDetailed(job, "error", 0, 100, 0, fmt.Sprintf("Transcription failed: %v", err))
+		if scheduleRetry(job, logger, err) {
+			return
+		}
+		failJob(job, 100, "Transcription failed", err)
 		return
 	}
-
-	// Step 3: Save result
+	
+	// Optional post-processing
+	detectLanguage(job, logger, prepared.path, transcript)
+	checkQuality(job, logger, prepared.path, transcript)
+	restoreTimeline(job, transcript, prepared.cuts)
+	applyGlossary(job, logger, transcript)
+	redactTranscript(job, logger, transcript)
+	diarizeTranscript(job, logger, audioFile, transcript)
+	summarizeTranscript(job, logger, transcript)
+
+	// Step 3: Save result. The transcript goes into the job state first, so
+	// a job interrupted from here on can be completed without redoing it.
+	jobsMu.Lock()
+	job.Text = transcript.Text
+	job.Segments = transcript.Segments
+	jobsMu.Unlock()
 	updateJobStatusDetailed(job, "saving", 90, 100, 90, "")
 	filename := fmt.Sprintf("%s.txt", job.ID)
-	filepath := filepath.Join("/data", filename)
+	filepath := filepath.Join(cfg.DataDir, filename)
 
-	if err := os.WriteFile(filepath, []byte(transcript), 0644); err != nil {
-		updateJobStatusDetailed(job, "error", 0, 100, 0, fmt.Sprintf("Save failed: %v", err))
+	if err := os.WriteFile(filepath, []byte(transcriptFileText(job, transcript)), 0644); err != nil {
+		failJob(job, 100, "Save failed", newPipelineError(codeSaveFailed, "%v", err))
 		return
 	}
 
+	// The WAV is only needed until here; keep a compressed copy for playback
+	storeAudio(job, logger)
+
 	// Complete
 	jobsMu.Lock()
 	job.Status = "done"
 	job.Progress = 100
 	job.AudioProgress = 100
 	job.TranscriptProgress = 100
-	job.Text = transcript
+	job.Text = transcript.Text
+	job.Segments = transcript.Segments
 	job.File = "/files/" + filename
 	jobsMu.Unlock()
 	
 	// Save final job state
 	saveJobToDisk(job)
+	removeChunkTranscripts(job.ID)
+	logger.Info("Job completed", "stage", "done")
 }
 
 // processJobResume resumes an interrupted job
 func processJobResume(job *Job) {
+	logger := jobLogger(job.ID)
+	resumeLogger := logger.With("stage", "resume")
 	defer func() {
 		if r := recover(); r != nil {
-			updateJobStatusDetailed(job, "error", 0, 0, 0, fmt.Sprintf("Internal error during resume: %v", r))
+			failJob(job, 0, "Internal error during resume", fmt.Errorf("%v", r))
 		}
 	}()
 	
-	log.Printf("Resuming job %s from status: %s", job.ID, job.Status)
+	resumeLogger.Info("Resuming job", "status", job.Status)
+	jobsMu.Lock()
+	job.NextRetry = nil
+	jobsMu.Unlock()
+	
+	// A job interrupted after saving its transcript only has to be marked
+	// done. The text and segments come from the job state; {id}.txt is the
+	// formatted rendering and cannot be read back.
+	filename := fmt.Sprintf("%s.txt", job.ID)
+	transcriptPath := filepath.Join(cfg.DataDir, filename)
+	jobsMu.RLock()
+	saved := job.Text != "" || len(job.Segments) > 0
+	jobsMu.RUnlock()
+	if _, err := os.Stat(transcriptPath); err == nil && saved {
+		storeAudio(job, logger)
+		jobsMu.Lock()
+		job.Status = "done"
+		job.Progress = 100
+		job.AudioProgress = 100
+		job.TranscriptProgress = 100
+		job.File = "/files/" + filename
+		jobsMu.Unlock()
+		saveJobToDisk(job)
+		removeChunkTranscripts(job.ID)
+		resumeLogger.Info("Job already completed, kept saved transcript")
+		return
+	}
 	
 	// Check if audio file already exists
 	audioFilename := fmt.Sprintf("%s.wav", job.ID)
-	audioPath := filepath.Join("/data", audioFilename)
-	tmpAudioPath := filepath.Join("/tmp", job.ID+".wav")
+	audioPath := filepath.Join(cfg.DataDir, audioFilename)
+	tmpAudioPath := filepath.Join(cfg.TempDir, job.ID+".wav")
 	
 	var audioFile string
 	
 	// If audio already downloaded, use existing file
 	if _, err := os.Stat(audioPath); err == nil {
-		log.Printf("Found existing audio file for job %s", job.ID)
+		resumeLogger.Info("Found existing audio file")
 		audioFile = audioPath
 		updateJobStatusDetailed(job, "transcribing", 50, 100, 0, "")
 	} else if _, err := os.Stat(tmpAudioPath); err == nil {
-		log.Printf("Found temporary audio file for job %s", job.ID)
+		resumeLogger.Info("Found temporary audio file")
 		audioFile = tmpAudioPath
 		// Copy to data directory
 		if err := copyFile(audioFile, audioPath); err == nil {
 			jobsMu.Lock()
-			job.AudioFile = "/files/" + audioFilename
+			job.AudioFile = audioURL(job.ID)
 			jobsMu.Unlock()
 		}
 		updateJobStatusDetailed(job, "transcribing", 50, 100, 0, "")
 	} else {
 		// Need to re-download audio (job was very early when interrupted)
-		log.Printf("No audio file found, need to restart download for job %s", job.ID)
+		resumeLogger.Info("No audio file found, restarting download")
 		if job.URL == "" {
-			updateJobStatusDetailed(job, "error", 0, 0, 0, "Cannot resume: original URL not saved")
+			failJob(job, 0, "Cannot resume", newPipelineError(codeResumeImpossible, "original URL not saved"))
 			return
 		}
 		
 		updateJobStatusDetailed(job, "downloading", 25, 0, 0, "")
-		downloadedAudio, err := downloadAudio(job.ID, job.URL)
+		var downloadedAudio string
+		err := withRetry(job, logger.With("stage", "download"), "download", func() error {
+			var err error
+			downloadedAudio, err = downloadAudio(job.ID, job.URL)
+			return err
+		})
 		if err != nil {
-			updateJobStatusDetailed(job, "error", 0, 0, 0, fmt.Sprintf("Download failed: %v", err))
+			if scheduleRetry(job, logger, err) {
+				return
+			}
+			failJob(job, 0, "Download failed", err)
 			return
 		}
 		
 		// Copy audio to data directory for serving
 		if err := copyFile(downloadedAudio, audioPath); err == nil {
 			jobsMu.Lock()
-			job.AudioFile = "/files/" + audioFilename
+			job.AudioFile = audioURL(job.ID)
 			jobsMu.Unlock()
 		}
 		
@@ -339,52 +759,78 @@ func processJobResume(job *Job) {
 		updateJobStatusDetailed(job, "transcribing", 50, 100, 0, "")
 	}
 	
-	// Check if transcript already exists
-	filename := fmt.Sprintf("%s.txt", job.ID)
-	filepath := filepath.Join("/data", filename)
-	if _, err := os.Stat(filepath); err == nil {
-		// Transcript already exists, load it
-		if data, err := os.ReadFile(filepath); err == nil {
-			jobsMu.Lock()
-			job.Status = "done"
-			job.Progress = 100
-			job.AudioProgress = 100
-			job.TranscriptProgress = 100
-			job.Text = string(data)
-			job.File = "/files/" + filename
-			jobsMu.Unlock()
-			saveJobToDisk(job)
-			log.Printf("Job %s already completed, loaded existing transcript", job.ID)
-			return
-		}
+	prepared := preprocessAudio(job, logger, audioFile)
+	defer prepared.remove(audioFile)
+	if prepared.path != audioFile {
+		updateJobStatusDetailed(job, "transcribing", 50, 100, 0, "")
 	}
 	
 	// Continue with transcription
-	transcript, err := transcribeAudio(audioFile)
+	transcript, err := transcribeAudio(job, prepared.path)
 	if err != nil {
-		updateJobStatusDetailed(job, "error", 0, 100, 0, fmt.Sprintf("Transcription failed: %v", err))
+		if scheduleRetry(job, logger, err) {
+			return
+		}
+		failJob(job, 100, "Transcription failed", err)
 		return
 	}
+	
+	// Optional post-processing
+	detectLanguage(job, logger, prepared.path, transcript)
+	checkQuality(job, logger, prepared.path, transcript)
+	restoreTimeline(job, transcript, prepared.cuts)
+	applyGlossary(job, logger, transcript)
+	redactTranscript(job, logger, transcript)
+	diarizeTranscript(job, logger, audioFile, transcript)
+	summarizeTranscript(job, logger, transcript)
 
 	// Save result
+	jobsMu.Lock()
+	job.Text = transcript.Text
+	job.Segments = transcript.Segments
+	jobsMu.Unlock()
 	updateJobStatusDetailed(job, "saving", 90, 100, 90, "")
-	if err := os.WriteFile(filepath, []byte(transcript), 0644); err != nil {
-		updateJobStatusDetailed(job, "error", 0, 100, 0, fmt.Sprintf("Save failed: %v", err))
+	if err := os.WriteFile(transcriptPath, []byte(transcriptFileText(job, transcript)), 0644); err != nil {
+		failJob(job, 100, "Save failed", newPipelineError(codeSaveFailed, "%v", err))
 		return
 	}
 
+	// The WAV is only needed until here; keep a compressed copy for playback
+	storeAudio(job, logger)
+
 	// Complete
 	jobsMu.Lock()
 	job.Status = "done"
 	job.Progress = 100
 	job.AudioProgress = 100
 	job.TranscriptProgress = 100
-	job.Text = transcript
+	job.Text = transcript.Text
+	job.Segments = transcript.Segments
 	job.File = "/files/" + filename
 	jobsMu.Unlock()
 	
 	saveJobToDisk(job)
-	log.Printf("Successfully resumed and completed job %s", job.ID)
+	removeChunkTranscripts(job.ID)
+	resumeLogger.Info("Successfully resumed and completed job")
+}
+
+// failJob marks a job as failed, recording the error code, a human message
+// and the captured output of the tool that failed.
+func failJob(job *Job, audioProgress int, context string, err error) {
+	code, output := errorDetails(err)
+	message := fmt.Sprintf("%s: %v", context, err)
+	if friendly, ok := errorMessages[code]; ok {
+		message = friendly
+	}
+	
+	jobLogger(job.ID).Error("Job failed", "error_code", code, "context", context, "error", err)
+	
+	jobsMu.Lock()
+	job.ErrorCode = code
+	job.ErrorOutput = output
+	jobsMu.Unlock()
+	
+	updateJobStatusDetailed(job, "error", 0, audioProgress, 0, message)
 }
 
 func updateJobStatus(job *Job, status string, progress int, error string) {
@@ -412,46 +858,83 @@ func updateJobStatusDetailed(job *Job, status string, overallProgress, audioProg
 	saveJobToDisk(job)
 }
 
+// copyFile streams src to dst, so hours of audio never sit in memory.
 func copyFile(src, dst string) error {
-	// Simple file copy using os.ReadFile/WriteFile
-	data, err := os.ReadFile(src)
+	in, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to read source file %s: %v", src, err)
 	}
+	defer in.Close()
+	
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to write destination file %s: %v", dst, err)
+	}
 	
-	err = os.WriteFile(dst, data, 0644)
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(dst)
 		return fmt.Errorf("failed to write destination file %s: %v", dst, err)
 	}
 	
-	log.Printf("Successfully copied audio file: %s -> %s (%d bytes)", src, dst, len(data))
+	slog.Debug("Copied file", "src", src, "dst", dst, "bytes", n)
 	return nil
 }
 
 func extractVideoMetadata(job *Job, url string) error {
+	defer metrics.observeStage("metadata", time.Now())
+	
 	// Use yt-dlp to get video metadata
 	cmd := exec.Command("yt-dlp", "--dump-json", "--no-download", url)
 	output, err := cmd.Output()
+	metrics.observeToolExit("yt-dlp", err)
 	if err != nil {
 		return fmt.Errorf("failed to extract metadata: %v", err)
 	}
 	
-	// Parse JSON output
+	jobsMu.Lock()
+	err = applyVideoMetadata(job, output)
+	jobsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	
+	jobLogger(job.ID).Info("Extracted video metadata", "stage", "metadata",
+		"title", job.Title, "duration", job.Duration, "channel", job.ChannelName,
+		"chapters", len(job.Chapters))
+	
+	return nil
+}
+
+// applyVideoMetadata copies the fields we keep from `yt-dlp --dump-json`
+// output onto the job. The caller holds jobsMu.
+func applyVideoMetadata(job *Job, output []byte) error {
 	var metadata struct {
-		Title       string  `json:"title"`
-		Description string  `json:"description"`
-		Thumbnail   string  `json:"thumbnail"`
-		Duration    float64 `json:"duration"`
-		Channel     string  `json:"channel"`
-		Uploader    string  `json:"uploader"`
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		Thumbnail   string   `json:"thumbnail"`
+		Duration    float64  `json:"duration"`
+		Channel     string   `json:"channel"`
+		Uploader    string   `json:"uploader"`
+		UploadDate  string   `json:"upload_date"`
+		Tags        []string `json:"tags"`
+		Language    string   `json:"language"`
+		ViewCount   int64    `json:"view_count"`
+		WebpageURL  string   `json:"webpage_url"`
+		Chapters    []struct {
+			StartTime float64 `json:"start_time"`
+			EndTime   float64 `json:"end_time"`
+			Title     string  `json:"title"`
+		} `json:"chapters"`
 	}
 	
 	if err := json.Unmarshal(output, &metadata); err != nil {
 		return fmt.Errorf("failed to parse metadata JSON: %v", err)
 	}
 	
-	// Update job with metadata
-	jobsMu.Lock()
 	job.Title = metadata.Title
 	job.Description = metadata.Description
 	job.Thumbnail = metadata.Thumbnail
@@ -460,16 +943,30 @@ func extractVideoMetadata(job *Job, url string) error {
 	if job.ChannelName == "" {
 		job.ChannelName = metadata.Uploader
 	}
-	jobsMu.Unlock()
-	
-	log.Printf("Extracted metadata for job %s: title=%s, duration=%ds, channel=%s", 
-		job.ID, job.Title, job.Duration, job.ChannelName)
-	
+	job.UploadDate = metadata.UploadDate
+	// yt-dlp reports YYYYMMDD
+	if len(job.UploadDate) == 8 {
+		job.UploadDate = job.UploadDate[:4] + "-" + job.UploadDate[4:6] + "-" + job.UploadDate[6:]
+	}
+	job.Tags = metadata.Tags
+	job.Language = metadata.Language
+	job.ViewCount = metadata.ViewCount
+	job.WebpageURL = metadata.WebpageURL
+	job.Chapters = nil
+	for _, ch := range metadata.Chapters {
+		job.Chapters = append(job.Chapters, Chapter{Start: ch.StartTime, End: ch.EndTime, Title: ch.Title})
+	}
 	return nil
 }
 
 func downloadAudio(jobID, url string) (string, error) {
-	tmpFile := filepath.Join("/tmp", jobID+".wav")
+	defer metrics.observeStage("download", time.Now())
+	
+	// ffmpeg writes to a .part file that is only renamed once the download
+	// is complete, so resume never mistakes a truncated WAV for a full one
+	tmpFile := filepath.Join(cfg.TempDir, jobID+".wav")
+	partFile := tmpFile + ".part"
+	defer os.Remove(partFile)
 
 	// Use yt-dlp to download best audio and pipe to ffmpeg for conversion
 	ytCmd := exec.Command("yt-dlp",
@@ -484,159 +981,345 @@ func downloadAudio(jobID, url string) (string, error) {
 		"-ac", "1",
 		"-ar", "16000",
 		"-f", "wav",
-		tmpFile,
+		partFile,
 		"-y") // Overwrite output file
 
+	// Keep stderr of both tools so failures can be classified
+	var ytStderr, ffStderr bytes.Buffer
+	ytCmd.Stderr = &ytStderr
+	ffCmd.Stderr = &ffStderr
+
 	// Connect yt-dlp output to ffmpeg input
 	pipe, err := ytCmd.StdoutPipe()
 	if err != nil {
-		return "", fmt.Errorf("failed to create pipe: %v", err)
+		return "", newPipelineError(codeInternal, "failed to create pipe: %v", err)
 	}
 	ffCmd.Stdin = pipe
 
 	// Start both commands
 	if err := ffCmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start ffmpeg: %v", err)
+		return "", newPipelineError(codeInternal, "failed to start ffmpeg: %v", err)
 	}
 
 	if err := ytCmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start yt-dlp: %v", err)
+		return "", newPipelineError(codeInternal, "failed to start yt-dlp: %v", err)
 	}
 
 	// Wait for completion
-	if err := ytCmd.Wait(); err != nil {
-		return "", fmt.Errorf("yt-dlp failed: %v", err)
+	err = ytCmd.Wait()
+	metrics.observeToolExit("yt-dlp", err)
+	if err != nil {
+		metrics.observeToolExit("ffmpeg", ffCmd.Wait())
+		return "", newToolError(classifyDownloadError(ytStderr.String()), ytStderr.String(), "yt-dlp failed: %v", err)
 	}
 
-	if err := ffCmd.Wait(); err != nil {
-		return "", fmt.Errorf("ffmpeg failed: %v", err)
+	err = ffCmd.Wait()
+	metrics.observeToolExit("ffmpeg", err)
+	if err != nil {
+		return "", newToolError(codeInvalidAudio, ffStderr.String(), "ffmpeg failed: %v", err)
 	}
 
+	if err := os.Rename(partFile, tmpFile); err != nil {
+		return "", newPipelineError(codeInternal, "failed to store downloaded audio: %v", err)
+	}
 	return tmpFile, nil
 }
 
-func transcribeAudio(audioFile string) (string, error) {
+func transcribeAudio(job *Job, audioFile string) (*Transcript, error) {
+	logger := jobLogger(job.ID).With("stage", "transcribe")
+	
 	// Check audio file size and split if too large
 	fileInfo, err := os.Stat(audioFile)
 	if err != nil {
-		return "", fmt.Errorf("cannot check audio file: %v", err)
+		return nil, newPipelineError(codeInvalidAudio, "cannot check audio file: %v", err)
 	}
 	
-	// If file is larger than 10MB, split into chunks
-	if fileInfo.Size() > 10*1024*1024 {
-		log.Printf("Audio file is large (%d bytes), splitting into chunks", fileInfo.Size())
-		return transcribeAudioChunked(audioFile)
+	// If file is larger than the configured threshold, split into chunks
+	if fileInfo.Size() > cfg.ChunkThresholdBytes {
+		logger.Info("Audio file is large, splitting into chunks", "bytes", fileInfo.Size())
+		return transcribeAudioChunked(job, logger, audioFile)
 	}
 	
 	// Process small files directly
-	return transcribeAudioDirect(audioFile)
+	prompt := jobPrompt(job)
+	var transcript *Transcript
+	err = withRetry(job, logger, "transcribe", func() error {
+		var err error
+		transcript, err = transcribeAudioDirect(logger, audioFile, whisperOptions{model: cfg.Model, prompt: prompt})
+		return err
+	})
+	return transcript, err
 }
 
-func transcribeAudioChunked(audioFile string) (string, error) {
-	chunks, err := splitAudioFile(audioFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to split audio: %v", err)
+// splitChunk and transcribeChunk are the per-chunk steps of
+// transcribeAudioChunked, replaced in tests that run without ffmpeg and whisper.
+var (
+	splitChunk      = splitAudioChunk
+	transcribeChunk = transcribeAudioDirect
+)
+
+// transcribeAudioChunked transcribes audioFile in fixed-size chunks, persisting
+// each chunk's transcript as soon as it completes so an interrupted job only
+// has to redo the chunks that were not finished.
+func transcribeAudioChunked(job *Job, logger *slog.Logger, audioFile string) (*Transcript, error) {
+	dir := chunkDir(job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create chunk directory: %v", err)
 	}
 	
-	var fullTranscript strings.Builder
-	for i, chunk := range chunks {
-		log.Printf("Processing chunk %d/%d", i+1, len(chunks))
+	totalChunks := estimateChunkCount(audioFile)
+	prompt := jobPrompt(job)
+	
+	fullTranscript := &Transcript{}
+	for i := 0; ; i++ {
+		chunkLogger := logger.With("chunk", i+1)
 		
-		text, err := transcribeAudioDirect(chunk)
+		// Reuse transcripts of chunks finished before an interruption
+		if done, ok := loadChunkTranscript(dir, i); ok {
+			chunkLogger.Info("Chunk already transcribed, skipping")
+			fullTranscript.append(done)
+			fullTranscript.addChunkLanguage(i, done)
+			updateChunkProgress(job, i+1, totalChunks)
+			continue
+		}
+		
+		chunk, err := splitChunk(audioFile, i)
 		if err != nil {
-			log.Printf("Chunk %d failed: %v", i+1, err)
-			continue // Skip failed chunks rather than fail entirely
+			if i == 0 {
+				return nil, newPipelineError(codeInvalidAudio, "failed to split audio: %v", err)
+			}
+			// No more audio to split
+			break
 		}
 		
-		fullTranscript.WriteString(text)
-		fullTranscript.WriteString(" ")
+		chunkLogger.Info("Processing chunk", "total_chunks", totalChunks)
+		
+		var part *Transcript
+		err = withRetry(job, chunkLogger, fmt.Sprintf("transcribe chunk %d", i+1), func() error {
+			var err error
+			part, err = transcribeChunk(chunkLogger, chunk, whisperOptions{model: cfg.Model, prompt: prompt})
+			return err
+		})
 		
 		// Clean up chunk file
 		os.Remove(chunk)
+		
+		// Finished chunks are kept, so the retry continues with this one
+		if isRetryLater(err) {
+			return nil, err
+		}
+		// A chunk of silence or music simply contributes no text
+		if code, _ := errorDetails(err); code == codeEmptyTranscript {
+			chunkLogger.Info("Chunk has no speech")
+			part, err = &Transcript{}, nil
+		}
+		if err != nil {
+			chunkLogger.Error("Chunk failed", "error", err)
+			continue // Skip failed chunks rather than fail entirely
+		}
+		
+		// Chunk timestamps are relative to the chunk; make them absolute
+		part.shift(float64(i * cfg.ChunkSeconds))
+		
+		if err := saveChunkTranscript(dir, i, part); err != nil {
+			chunkLogger.Warn("Failed to persist chunk transcript", "error", err)
+		}
+		
+		fullTranscript.append(part)
+		fullTranscript.addChunkLanguage(i, part)
+		updateChunkProgress(job, i+1, totalChunks)
 	}
 	
-	return strings.TrimSpace(fullTranscript.String()), nil
+	return fullTranscript, nil
 }
 
-func splitAudioFile(audioFile string) ([]string, error) {
+// splitAudioChunk extracts the index-th chunk of audioFile into its own WAV
+// file next to the source. It returns an error once index is past the end of
+// the audio.
+func splitAudioChunk(audioFile string, index int) (string, error) {
+	defer metrics.observeStage("split", time.Now())
+	
 	baseDir := filepath.Dir(audioFile)
 	baseName := strings.TrimSuffix(filepath.Base(audioFile), ".wav")
 	
-	chunks := []string{}
-	chunkDuration := 120 // 2 minutes per chunk
+	chunkFile := filepath.Join(baseDir, fmt.Sprintf("%s_chunk_%d.wav", baseName, index))
+	startTime := index * cfg.ChunkSeconds
 	
-	// Use ffmpeg to split audio into 2-minute chunks
-	for i := 0; i < 10; i++ { // Max 10 chunks (20 minutes total)
-		chunkFile := filepath.Join(baseDir, fmt.Sprintf("%s_chunk_%d.wav", baseName, i))
-		startTime := i * chunkDuration
-		
-		cmd := exec.Command("ffmpeg",
-			"-i", audioFile,
-			"-ss", fmt.Sprintf("%d", startTime),
-			"-t", fmt.Sprintf("%d", chunkDuration),
-			"-c", "copy",
-			chunkFile,
-			"-y") // Overwrite output
-		
-		err := cmd.Run()
-		if err != nil {
-			// No more audio to split
-			break
-		}
-		
-		// Check if chunk has content
-		if info, err := os.Stat(chunkFile); err == nil && info.Size() > 1000 {
-			chunks = append(chunks, chunkFile)
-		} else {
-			os.Remove(chunkFile)
-			break
-		}
+	cmd := exec.Command("ffmpeg",
+		"-i", audioFile,
+		"-ss", fmt.Sprintf("%d", startTime),
+		"-t", fmt.Sprintf("%d", cfg.ChunkSeconds),
+		"-c", "copy",
+		chunkFile,
+		"-y") // Overwrite output
+	
+	err := cmd.Run()
+	metrics.observeToolExit("ffmpeg", err)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg split failed: %v", err)
 	}
 	
-	return chunks, nil
+	// Check if chunk has content
+	if info, err := os.Stat(chunkFile); err != nil || info.Size() <= 1000 {
+		os.Remove(chunkFile)
+		return "", fmt.Errorf("chunk %d is empty", index)
+	}
+	
+	return chunkFile, nil
+}
+
+// chunkDir returns the directory holding per-chunk transcripts of a job.
+func chunkDir(jobID string) string {
+	return filepath.Join(cfg.JobsDir, jobID, "chunks")
+}
+
+func chunkTranscriptPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("chunk_%04d.json", index))
+}
+
+// loadChunkTranscript returns the persisted transcript of a chunk, if any.
+func loadChunkTranscript(dir string, index int) (*Transcript, bool) {
+	data, err := os.ReadFile(chunkTranscriptPath(dir, index))
+	if err != nil {
+		return nil, false
+	}
+	var tr Transcript
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return nil, false
+	}
+	return &tr, true
+}
+
+// saveChunkTranscript writes a chunk transcript via a temporary file so that a
+// crash mid-write never leaves a truncated chunk that resume would trust.
+func saveChunkTranscript(dir string, index int, tr *Transcript) error {
+	data, err := json.Marshal(tr)
+	if err != nil {
+		return err
+	}
+	path := chunkTranscriptPath(dir, index)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeChunkTranscripts deletes the chunk transcripts of a finished job.
+func removeChunkTranscripts(jobID string) {
+	dir := chunkDir(jobID)
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("Failed to remove chunk transcripts", "job_id", jobID, "error", err)
+		return
+	}
+	// Drop the per-job directory too if nothing else lives in it
+	os.Remove(filepath.Dir(dir))
+}
+
+// estimateChunkCount derives the number of chunks from the length of the
+// audio. It is only used for progress reporting.
+func estimateChunkCount(audioFile string) int {
+	seconds := int(wavSeconds(audioFile))
+	count := (seconds + cfg.ChunkSeconds - 1) / cfg.ChunkSeconds
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// wavSeconds estimates the duration of a 16 kHz mono 16-bit WAV file from its
+// size, which is how every file in the pipeline is encoded.
+func wavSeconds(audioFile string) float64 {
+	info, err := os.Stat(audioFile)
+	if err != nil || info.Size() <= 44 {
+		return 0
+	}
+	return float64(info.Size()-44) / (16000 * 2)
 }
 
-func transcribeAudioDirect(audioFile string) (string, error) {
-	log.Printf("Transcribing audio file: %s", filepath.Base(audioFile))
+func updateChunkProgress(job *Job, done, total int) {
+	progress := done * 100 / total
+	if progress > 99 {
+		progress = 99
+	}
+	
+	jobsMu.Lock()
+	job.TranscriptProgress = progress
+	job.Progress = 50 + progress*40/100
+	jobsMu.Unlock()
+	
+	saveJobToDisk(job)
+}
+
+// whisperOptions select the model for one whisper run. A non-empty prompt is
+// passed as the initial prompt to steer spelling, a non-empty language skips
+// whisper's own language detection.
+type whisperOptions struct {
+	model    string
+	language string
+	prompt   string
+}
+
+// transcribeAudioDirect runs whisper on one file.
+func transcribeAudioDirect(logger *slog.Logger, audioFile string, opts whisperOptions) (*Transcript, error) {
+	logger.Info("Transcribing audio file", "file", filepath.Base(audioFile))
 	
 	// Use OpenAI Whisper binary for transcription
 	baseName := strings.TrimSuffix(filepath.Base(audioFile), ".wav")
-	outputDir := "/tmp"
+	outputDir := cfg.TempDir
 	
 	// Run whisper command
-	cmd := exec.Command("whisper", 
+	args := []string{
 		audioFile,
-		"--model", "tiny",
-		"--output_format", "txt",
+		"--model", opts.model,
+		"--output_format", "json",
 		"--output_dir", outputDir,
-		"--verbose", "False")
+		"--verbose", "False",
+	}
+	if cfg.WordTimestamps {
+		args = append(args, "--word_timestamps", "True")
+	}
+	if opts.prompt != "" {
+		args = append(args, "--initial_prompt", opts.prompt)
+	}
+	if opts.language != "" {
+		args = append(args, "--language", opts.language)
+	}
+	cmd := exec.Command(cfg.WhisperBin, args...)
 	
 	// Capture both stdout and stderr for debugging
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	metrics.observeStage("transcribe", start)
+	metrics.observeToolExit("whisper", err)
 	if err != nil {
-		log.Printf("Whisper command failed: %v, output: %s", err, string(output))
-		return "", fmt.Errorf("whisper transcription failed: %v", err)
+		logger.Error("Whisper command failed", "error", err, "output", outputTail(string(output)))
+		return nil, newToolError(classifyEngineError(err, string(output)), string(output), "whisper transcription failed: %v", err)
 	}
 	
-	log.Printf("Whisper command completed successfully")
+	logger.Debug("Whisper command completed successfully")
+	metrics.observeTranscription(opts.model, wavSeconds(audioFile), time.Since(start))
 	
 	// Read the generated transcript file
-	transcriptFile := filepath.Join(outputDir, baseName+".txt")
+	transcriptFile := filepath.Join(outputDir, baseName+".json")
 	transcriptBytes, err := os.ReadFile(transcriptFile)
 	if err != nil {
-		log.Printf("Failed to read transcript file %s: %v", transcriptFile, err)
-		return "", fmt.Errorf("failed to read transcript file: %v", err)
-	}
-	
-	transcript := strings.TrimSpace(string(transcriptBytes))
-	if transcript == "" {
-		return "", fmt.Errorf("empty transcript generated")
+		logger.Error("Failed to read transcript file", "file", transcriptFile, "error", err)
+		return nil, newPipelineError(codeEngineFailed, "failed to read transcript file: %v", err)
 	}
 	
 	// Clean up the transcript file
 	os.Remove(transcriptFile)
 	
-	log.Printf("Successfully transcribed audio, transcript length: %d characters", len(transcript))
+	transcript, err := parseWhisperJSON(transcriptBytes)
+	if err != nil {
+		return nil, newPipelineError(codeEngineFailed, "%v", err)
+	}
+	if transcript.Text == "" {
+		return nil, newPipelineError(codeEmptyTranscript, "empty transcript generated")
+	}
+	
+	logger.Info("Successfully transcribed audio", "characters", len(transcript.Text), "segments", len(transcript.Segments))
 	return transcript, nil
 }
 
@@ -647,10 +1330,10 @@ func parseWhisperResponse(output []byte) (string, error) {
 	}
 
 	// Log the raw response for debugging
-	log.Printf("Whisper response: %s", string(output))
+	slog.Debug("Whisper response", "output", string(output))
 
 	if err := json.Unmarshal(output, &response); err != nil {
-		log.Printf("JSON parsing failed: %v, raw response: %s", err, string(output))
+		slog.Warn("JSON parsing failed", "error", err, "output", string(output))
 		// If JSON parsing fails, assume raw text response
 		return string(output), nil
 	}
@@ -661,7 +1344,7 @@ func parseWhisperResponse(output []byte) (string, error) {
 
 	// Validate transcript quality  
 	if len(response.Text) < 10 {
-		log.Printf("Warning: Very short transcript (%d chars): %s", len(response.Text), response.Text)
+		slog.Warn("Very short transcript", "characters", len(response.Text), "text", response.Text)
 	}
 
 	return response.Text, nil
@@ -670,7 +1353,7 @@ func parseWhisperResponse(output []byte) (string, error) {
 func parseWhisperCppResponse(output []byte) string {
 	// whisper.cpp returns different format than go-whisper
 	response := string(output)
-	log.Printf("Whisper.cpp raw response: %s", response)
+	slog.Debug("Whisper.cpp raw response", "output", response)
 	
 	// Try to parse as JSON first
 	var jsonResp struct {
@@ -693,31 +1376,44 @@ func isValidYouTubeURL(url string) bool {
 
 // saveJobToDisk saves job state to disk for persistence
 func saveJobToDisk(job *Job) {
-	jobFile := filepath.Join("/data/jobs", job.ID+".json")
+	jobFile := filepath.Join(cfg.JobsDir, job.ID+".json")
+	// Translations update jobs concurrently with the worker
+	jobsMu.RLock()
 	data, err := json.MarshalIndent(job, "", "  ")
+	jobsMu.RUnlock()
 	if err != nil {
-		log.Printf("Error marshaling job %s: %v", job.ID, err)
+		slog.Error("Error marshaling job", "job_id", job.ID, "error", err)
 		return
 	}
 	
 	if err := os.WriteFile(jobFile, data, 0644); err != nil {
-		log.Printf("Error saving job %s to disk: %v", job.ID, err)
+		slog.Error("Error saving job to disk", "job_id", job.ID, "error", err)
 	}
 }
 
 // backgroundWorker processes jobs from the queue one at a time
 func backgroundWorker() {
-	log.Println("Background worker started")
+	slog.Info("Background worker started")
 	
-	for jobID := range jobQueue {
-		log.Printf("Processing job %s from queue", jobID)
+	for item := range jobQueue {
+		slog.Info("Processing job from queue", "job_id", item.ID, "resume", item.Resume)
 		
 		jobsMu.RLock()
-		job, exists := jobs[jobID]
+		job, exists := jobs[item.ID]
 		jobsMu.RUnlock()
 		
 		if !exists {
-			log.Printf("Job %s not found in memory", jobID)
+			slog.Warn("Job not found in memory", "job_id", item.ID)
+			continue
+		}
+		
+		metrics.workerStarted()
+		
+		// Interrupted jobs pick up from whatever they already produced
+		if item.Resume {
+			processJobResume(job)
+			metrics.workerFinished()
+			slog.Info("Completed resumed job", "job_id", item.ID)
 			continue
 		}
 		
@@ -728,20 +1424,38 @@ func backgroundWorker() {
 		
 		// Process the job
 		processJob(job, job.URL)
+		metrics.workerFinished()
 		
-		log.Printf("Completed processing job %s", jobID)
+		slog.Info("Completed processing job", "job_id", item.ID)
+	}
+}
+
+// enqueueRecoveredJobs hands interrupted jobs to the background worker in
+// their original creation order. It blocks while the queue is full, so it is
+// run in its own goroutine at startup.
+func enqueueRecoveredJobs(recovered []*Job) {
+	sort.Slice(recovered, func(i, j int) bool {
+		return recovered[i].Created.Before(recovered[j].Created)
+	})
+	
+	for _, job := range recovered {
+		jobLogger(job.ID).Info("Re-queueing interrupted job", "stage", "queue")
+		jobQueue <- queuedJob{ID: job.ID, Resume: true}
 	}
 }
 
-// loadJobsFromDisk loads existing jobs from disk on startup
-func loadJobsFromDisk() {
-	jobsDir := "/data/jobs"
+// loadJobsFromDisk loads existing jobs from disk on startup and returns the
+// ones that were interrupted and still need processing
+func loadJobsFromDisk() []*Job {
+	jobsDir := cfg.JobsDir
 	files, err := os.ReadDir(jobsDir)
 	if err != nil {
-		log.Printf("Could not read jobs directory: %v", err)
-		return
+		slog.Warn("Could not read jobs directory", "dir", jobsDir, "error", err)
+		return nil
 	}
 	
+	var recovered []*Job
+	
 	loadedCount := 0
 	for _, file := range files {
 		if !strings.HasSuffix(file.Name(), ".json") {
@@ -751,32 +1465,47 @@ func loadJobsFromDisk() {
 		jobFile := filepath.Join(jobsDir, file.Name())
 		data, err := os.ReadFile(jobFile)
 		if err != nil {
-			log.Printf("Error reading job file %s: %v", jobFile, err)
+			slog.Error("Error reading job file", "file", jobFile, "error", err)
 			continue
 		}
 		
 		var job Job
 		if err := json.Unmarshal(data, &job); err != nil {
-			log.Printf("Error unmarshaling job file %s: %v", jobFile, err)
+			slog.Error("Error unmarshaling job file", "file", jobFile, "error", err)
 			continue
 		}
 		
 		jobsMu.Lock()
+		if job.DeletedAt != nil {
+			trash[job.ID] = &job
+			jobsMu.Unlock()
+			continue
+		}
 		jobs[job.ID] = &job
 		jobsMu.Unlock()
 		
 		// Resume processing if job was interrupted
 		if job.Status != "done" && job.Status != "error" {
-			log.Printf("Resuming interrupted job: %s", job.ID)
+			slog.Info("Resuming interrupted job", "job_id", job.ID)
 			// Reset status to allow resumption
+			jobsMu.Lock()
 			job.Status = "queued"
-			go processJobResume(&job)
+			jobsMu.Unlock()
+			saveJobToDisk(&job)
+			recovered = append(recovered, &job)
+		}
+		
+		// Translations run outside the queue and do not survive a restart
+		if markInterruptedTranslations(&job) {
+			saveJobToDisk(&job)
 		}
 		
 		loadedCount++
 	}
 	
 	if loadedCount > 0 {
-		log.Printf("Loaded %d jobs from disk", loadedCount)
+		slog.Info("Loaded jobs from disk", "count", loadedCount)
 	}
+	
+	return recovered
 }
@@ -2,8 +2,12 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -144,4 +148,229 @@ func TestYouTubeTranscription(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestEnqueueRecoveredJobsPreservesCreationOrder(t *testing.T) {
+	now := time.Now()
+	recovered := []*Job{
+		{ID: "third", Created: now},
+		{ID: "first", Created: now.Add(-2 * time.Hour)},
+		{ID: "second", Created: now.Add(-time.Hour)},
+	}
+
+	enqueueRecoveredJobs(recovered)
+
+	for _, want := range []string{"first", "second", "third"} {
+		item := <-jobQueue
+		if item.ID != want {
+			t.Errorf("Expected job %s, got %s", want, item.ID)
+		}
+		if !item.Resume {
+			t.Errorf("Expected job %s to be marked for resume", item.ID)
+		}
+	}
+}
+
+func TestApplyVideoMetadata(t *testing.T) {
+	output := []byte(`{
+		"title": "Talk", "channel": "", "uploader": "Conf", "duration": 300.5,
+		"upload_date": "20240131", "tags": ["go", "audio"], "language": "en",
+		"view_count": 1234, "webpage_url": "https://www.youtube.com/watch?v=abc",
+		"chapters": [
+			{"start_time": 0, "end_time": 60, "title": "Intro"},
+			{"start_time": 60, "end_time": 300.5, "title": "Main part"}
+		]
+	}`)
+
+	job := &Job{ID: "metadata-test"}
+	if err := applyVideoMetadata(job, output); err != nil {
+		t.Fatalf("Failed to apply metadata: %v", err)
+	}
+	if job.ChannelName != "Conf" || job.Duration != 300 || job.UploadDate != "2024-01-31" {
+		t.Errorf("Unexpected metadata: %+v", job)
+	}
+	if len(job.Tags) != 2 || job.Language != "en" || job.ViewCount != 1234 || job.WebpageURL == "" {
+		t.Errorf("Expected tags, language, views and page URL, got %+v", job)
+	}
+	if len(job.Chapters) != 2 || job.Chapters[1].Start != 60 || job.Chapters[1].Title != "Main part" {
+		t.Errorf("Unexpected chapters %+v", job.Chapters)
+	}
+}
+
+func TestTranscribeAudioChunkedResumes(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	c.ChunkSeconds = 60
+	cfg = &c
+
+	savedSplit, savedTranscribe := splitChunk, transcribeChunk
+	defer func() { splitChunk, transcribeChunk = savedSplit, savedTranscribe }()
+
+	splitChunk = func(audioFile string, index int) (string, error) {
+		if index >= 5 {
+			return "", fmt.Errorf("chunk %d is empty", index)
+		}
+		return fmt.Sprintf("chunk_%d.wav", index), nil
+	}
+	var transcribed []string
+	transcribeChunk = func(_ *slog.Logger, audioFile string, _ whisperOptions) (*Transcript, error) {
+		transcribed = append(transcribed, audioFile)
+		if audioFile == "chunk_4.wav" {
+			return nil, newPipelineError(codeEmptyTranscript, "empty transcript generated")
+		}
+		return &Transcript{
+			Text:     "new " + audioFile,
+			Segments: []Segment{{Start: 1, End: 2, Text: "new " + audioFile}},
+		}, nil
+	}
+
+	// Chunks 0 and 2 finished before the interruption
+	job := &Job{ID: "chunk-resume-test"}
+	dir := chunkDir(job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 2} {
+		done := &Transcript{
+			Text:     fmt.Sprintf("done %d", i),
+			Segments: []Segment{{Start: float64(i*60) + 1, End: float64(i*60) + 2, Text: fmt.Sprintf("done %d", i)}},
+		}
+		if err := saveChunkTranscript(dir, i, done); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tr, err := transcribeAudioChunked(job, slog.Default(), filepath.Join(t.TempDir(), "audio.wav"))
+	if err != nil {
+		t.Fatalf("Chunked transcription failed: %v", err)
+	}
+
+	if strings.Join(transcribed, ",") != "chunk_1.wav,chunk_3.wav,chunk_4.wav" {
+		t.Errorf("Expected only the missing chunks to be transcribed, got %v", transcribed)
+	}
+	if tr.Text != "done 0 new chunk_1.wav done 2 new chunk_3.wav" {
+		t.Errorf("Unexpected text %q", tr.Text)
+	}
+	if len(tr.Segments) != 4 || tr.Segments[1].Start != 61 || tr.Segments[3].Start != 181 {
+		t.Errorf("Expected chunk-relative times to be shifted, got %+v", tr.Segments)
+	}
+	if _, ok := loadChunkTranscript(dir, 3); !ok {
+		t.Error("Expected the new chunk transcript to be persisted")
+	}
+	if empty, ok := loadChunkTranscript(dir, 4); !ok || empty.Text != "" {
+		t.Error("Expected a chunk without speech to be kept as an empty transcript")
+	}
+}
+
+func TestProcessJobResumeKeepsSavedTranscript(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.JobsDir = t.TempDir()
+	c.TempDir = t.TempDir()
+	cfg = &c
+
+	segments := []Segment{{Start: 0, End: 2, Text: "Hello there.", Speaker: "SPEAKER_1"}}
+	job := &Job{ID: "resume-saved-test", Status: "queued", Text: "Hello there.", Segments: segments}
+	os.WriteFile(filepath.Join(c.DataDir, job.ID+".txt"), []byte("[00:00:00] SPEAKER_1: Hello there.\n"), 0644)
+
+	processJobResume(job)
+
+	if job.Status != "done" || job.File != "/files/resume-saved-test.txt" {
+		t.Fatalf("Expected the job to be completed, got status %q file %q", job.Status, job.File)
+	}
+	if job.Text != "Hello there." || len(job.Segments) != 1 || job.Segments[0].Speaker != "SPEAKER_1" {
+		t.Errorf("Expected the saved transcript to be kept, got %q %+v", job.Text, job.Segments)
+	}
+
+	// Without a transcript in the job state the rendered file is not trusted
+	job = &Job{ID: "resume-rendered-test", Status: "queued"}
+	os.WriteFile(filepath.Join(c.DataDir, job.ID+".txt"), []byte("rendered"), 0644)
+
+	processJobResume(job)
+
+	if job.Status != "error" || job.Text != "" {
+		t.Errorf("Expected the job to be processed again, got status %q text %q", job.Status, job.Text)
+	}
+}
+
+func TestGetJobWhileRenamingSpeakers(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	cfg = &c
+
+	id := "00000000-0000-0000-0000-0000000000e1"
+	job := &Job{
+		ID:       id,
+		Status:   "done",
+		Created:  time.Now(),
+		Segments: []Segment{{Start: 0, End: 1, Text: "Hi", Speaker: "SPEAKER_1"}},
+	}
+	jobsMu.Lock()
+	jobs[id] = job
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, id)
+		jobsMu.Unlock()
+	}()
+
+	// Run with -race: encoding must not read SpeakerNames while it is renamed
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			body := strings.NewReader(fmt.Sprintf(`{"SPEAKER_1": "Speaker %d"}`, i))
+			handleJobRoutes(httptest.NewRecorder(), httptest.NewRequest("PUT", "/job/"+id+"/speakers", body))
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		handleJobRoutes(httptest.NewRecorder(), httptest.NewRequest("GET", "/job/"+id, nil))
+		handleGetActiveJobs(httptest.NewRecorder(), httptest.NewRequest("GET", "/jobs/active", nil))
+		handleGetJobHistory(httptest.NewRecorder(), httptest.NewRequest("GET", "/jobs/history", nil))
+	}
+	<-done
+
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/"+id, nil))
+	var got Job
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode job: %v", err)
+	}
+	if got.SpeakerNames["SPEAKER_1"] != "Speaker 49" {
+		t.Errorf("Expected the last rename, got %v", got.SpeakerNames)
+	}
+}
+
+func TestCORSAllowsAPIKeyHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/jobs/active", handleGetActiveJobs},
+		{"/jobs/history", handleGetJobHistory},
+		{"/jobs/trash", handleGetTrash},
+		{"/glossary", handleGetGlossary},
+		{"/files/missing.txt", handleFiles},
+		{"/job/missing", handleJobRoutes},
+		{"/job/missing/export", handleJobRoutes},
+		{"/job/missing/speakers", handleJobRoutes},
+		{"/job/missing/transcript", handleJobRoutes},
+	} {
+		req := httptest.NewRequest("OPTIONS", tc.path, nil)
+		req.Header.Set("Access-Control-Request-Headers", "x-api-key")
+		rec := httptest.NewRecorder()
+		tc.handler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected preflight to succeed, got %d", tc.path, rec.Code)
+		}
+		if allowed := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, "X-API-Key") || !strings.Contains(allowed, "Authorization") {
+			t.Errorf("%s: expected API key headers to be allowed, got %q", tc.path, allowed)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pipeline metrics are kept in memory and rendered in the Prometheus text
+// exposition format by handleMetrics. There is deliberately no client
+// library; the handful of counters and histograms below is all we need.
+
+var (
+	stageDurationBuckets  = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+	realtimeFactorBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 4, 8}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type toolExitKey struct {
+	tool string
+	code string
+}
+
+type metricsRegistry struct {
+	mu             sync.Mutex
+	activeWorkers  int
+	audioSeconds   float64
+	stageDurations map[string]*histogram
+	realtimeFactor map[string]*histogram
+	toolExits      map[toolExitKey]uint64
+}
+
+var metrics = newMetricsRegistry()
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		stageDurations: make(map[string]*histogram),
+		realtimeFactor: make(map[string]*histogram),
+		toolExits:      make(map[toolExitKey]uint64),
+	}
+}
+
+// workerStarted and workerFinished track how many jobs are being processed.
+func (m *metricsRegistry) workerStarted() {
+	m.mu.Lock()
+	m.activeWorkers++
+	m.mu.Unlock()
+}
+
+func (m *metricsRegistry) workerFinished() {
+	m.mu.Lock()
+	m.activeWorkers--
+	m.mu.Unlock()
+}
+
+// observeStage records the duration of a pipeline stage that began at start.
+// It is meant to be deferred: defer metrics.observeStage("download", time.Now())
+func (m *metricsRegistry) observeStage(stage string, start time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.stageDurations[stage]
+	if !ok {
+		h = newHistogram(stageDurationBuckets)
+		m.stageDurations[stage] = h
+	}
+	h.observe(time.Since(start).Seconds())
+}
+
+// observeTranscription records how much audio a model transcribed and how
+// long it took relative to the audio length.
+func (m *metricsRegistry) observeTranscription(model string, audioSeconds float64, elapsed time.Duration) {
+	if audioSeconds <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audioSeconds += audioSeconds
+	h, ok := m.realtimeFactor[model]
+	if !ok {
+		h = newHistogram(realtimeFactorBuckets)
+		m.realtimeFactor[model] = h
+	}
+	h.observe(elapsed.Seconds() / audioSeconds)
+}
+
+// observeToolExit counts the exit status of an external tool run. err is the
+// error returned by exec.Cmd.Run/Wait.
+func (m *metricsRegistry) observeToolExit(tool string, err error) {
+	code := "0"
+	if err != nil {
+		code = "error"
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.ExitCode() >= 0 {
+				code = strconv.Itoa(exitErr.ExitCode())
+			} else {
+				code = "signal"
+			}
+		}
+	}
+	m.mu.Lock()
+	m.toolExits[toolExitKey{tool: tool, code: code}]++
+	m.mu.Unlock()
+}
+
+// jobStatusCounts returns the number of jobs per status. The main statuses are
+// always present so dashboards see zeros rather than missing series.
+func jobStatusCounts() map[string]int {
+	counts := map[string]int{"queued": 0, "done": 0, "error": 0}
+	jobsMu.RLock()
+	for _, job := range jobs {
+		counts[job.Status]++
+	}
+	jobsMu.RUnlock()
+	return counts
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.write(w, len(jobQueue), jobStatusCounts())
+}
+
+func (m *metricsRegistry) write(w io.Writer, queueLength int, statusCounts map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(w, "vtranscribe_queue_length", "gauge", "Number of jobs waiting in the queue.")
+	fmt.Fprintf(w, "vtranscribe_queue_length %d\n", queueLength)
+
+	writeHeader(w, "vtranscribe_active_workers", "gauge", "Number of jobs currently being processed.")
+	fmt.Fprintf(w, "vtranscribe_active_workers %d\n", m.activeWorkers)
+
+	writeHeader(w, "vtranscribe_jobs", "gauge", "Number of known jobs by status.")
+	for _, status := range sortedKeys(statusCounts) {
+		fmt.Fprintf(w, "vtranscribe_jobs{status=%q} %d\n", status, statusCounts[status])
+	}
+
+	writeHeader(w, "vtranscribe_stage_duration_seconds", "histogram", "Duration of pipeline stages.")
+	for _, stage := range sortedKeys(m.stageDurations) {
+		writeHistogram(w, "vtranscribe_stage_duration_seconds", fmt.Sprintf("stage=%q", stage), m.stageDurations[stage])
+	}
+
+	writeHeader(w, "vtranscribe_audio_seconds_processed_total", "counter", "Seconds of audio transcribed.")
+	fmt.Fprintf(w, "vtranscribe_audio_seconds_processed_total %s\n", formatFloat(m.audioSeconds))
+
+	writeHeader(w, "vtranscribe_realtime_factor", "histogram", "Transcription time divided by audio duration.")
+	for _, model := range sortedKeys(m.realtimeFactor) {
+		writeHistogram(w, "vtranscribe_realtime_factor", fmt.Sprintf("model=%q", model), m.realtimeFactor[model])
+	}
+
+	writeHeader(w, "vtranscribe_tool_exits_total", "counter", "External tool runs by exit code.")
+	keys := make([]toolExitKey, 0, len(m.toolExits))
+	for k := range m.toolExits {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tool != keys[j].tool {
+			return keys[i].tool < keys[j].tool
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "vtranscribe_tool_exits_total{tool=%q,code=%q} %d\n", k.tool, k.code, m.toolExits[k])
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, formatFloat(upper), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	m := newMetricsRegistry()
+	m.workerStarted()
+	m.observeStage("download", time.Now().Add(-3*time.Second))
+	m.observeTranscription("tiny", 120, 60*time.Second)
+	m.observeToolExit("whisper", nil)
+	m.observeToolExit("yt-dlp", errors.New("failed to start"))
+
+	var buf bytes.Buffer
+	m.write(&buf, 2, map[string]int{"done": 4, "queued": 2})
+	out := buf.String()
+
+	expected := []string{
+		"vtranscribe_queue_length 2",
+		"vtranscribe_active_workers 1",
+		`vtranscribe_jobs{status="done"} 4`,
+		`vtranscribe_stage_duration_seconds_bucket{stage="download",le="1"} 0`,
+		`vtranscribe_stage_duration_seconds_bucket{stage="download",le="5"} 1`,
+		`vtranscribe_stage_duration_seconds_count{stage="download"} 1`,
+		"vtranscribe_audio_seconds_processed_total 120",
+		`vtranscribe_realtime_factor_sum{model="tiny"} 0.5`,
+		`vtranscribe_tool_exits_total{tool="whisper",code="0"} 1`,
+		`vtranscribe_tool_exits_total{tool="yt-dlp",code="error"} 1`,
+		"# TYPE vtranscribe_stage_duration_seconds histogram",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected metrics output to contain %q\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// audioFilters are the ffmpeg filters presets are made of. trim_silence is
+// built per file from the silences ffmpeg finds, see silenceFilter.
+var audioFilters = map[string]string{
+	"loudnorm":     "loudnorm=I=-16:TP=-1.5:LRA=11",
+	"highpass":     "highpass=f=80",
+	"denoise":      "afftdn=nf=-25",
+	"trim_silence": "",
+}
+
+// builtinPresets are always available; audio_presets in the config file can
+// add more or replace these. A filter name on its own also works as a preset.
+var builtinPresets = map[string][]string{
+	"none":    {},
+	"voice":   {"highpass", "loudnorm"},
+	"noisy":   {"highpass", "denoise", "loudnorm"},
+	"lecture": {"highpass", "denoise", "loudnorm", "trim_silence"},
+}
+
+const (
+	// Silences quieter than silenceNoise and longer than minSilence are
+	// trimmed, keeping silenceMargin seconds on either side.
+	silenceNoise  = "-45dB"
+	minSilence    = 2.0
+	silenceMargin = 0.3
+
+	// Audio is cut in frames of this many samples, so the removed time is
+	// known exactly and timestamps can be mapped back.
+	trimFrameSamples = 160
+)
+
+// presetFilters returns the filters of a preset.
+func (c *Config) presetFilters(name string) ([]string, error) {
+	if filters, ok := c.AudioPresets[name]; ok {
+		return filters, nil
+	}
+	if filters, ok := builtinPresets[name]; ok {
+		return filters, nil
+	}
+	if _, ok := audioFilters[name]; ok {
+		return []string{name}, nil
+	}
+	return nil, fmt.Errorf("unknown audio preset %q", name)
+}
+
+// validatePresets checks that custom presets only use known filters.
+func validatePresets(presets map[string][]string) error {
+	for name, filters := range presets {
+		for _, f := range filters {
+			if _, ok := audioFilters[f]; !ok {
+				return fmt.Errorf("audio preset %s: unknown filter %q", name, f)
+			}
+		}
+	}
+	return nil
+}
+
+// audioCut is a stretch of the original audio, in seconds, that was removed
+// from the processed copy.
+type audioCut struct {
+	start, end float64
+}
+
+// preparedAudio is the audio to transcribe: a processed copy of the original
+// or the original itself.
+type preparedAudio struct {
+	path string
+	cuts []audioCut
+}
+
+// preprocessAudio applies the job's preset to audioFile. The original is
+// left untouched for playback and diarization. On failure the original is
+// transcribed instead.
+func preprocessAudio(job *Job, logger *slog.Logger, audioFile string) preparedAudio {
+	jobsMu.RLock()
+	preset := job.Preset
+	jobsMu.RUnlock()
+
+	original := preparedAudio{path: audioFile}
+	filters, err := cfg.presetFilters(preset)
+	if preset == "" || err != nil || len(filters) == 0 {
+		return original
+	}
+
+	logger = logger.With("stage", "preprocess", "preset", preset)
+	updateJobStatusDetailed(job, "preprocessing", 48, 100, 0, "")
+	defer metrics.observeStage("preprocess", time.Now())
+
+	var chain []string
+	var cuts []audioCut
+	for _, name := range filters {
+		if name != "trim_silence" {
+			chain = append(chain, audioFilters[name])
+			continue
+		}
+		filter, found, err := silenceFilter(audioFile)
+		if err != nil {
+			logger.Warn("Silence detection failed, keeping silences", "error", err)
+			continue
+		}
+		if filter != "" {
+			// Cutting must see the original timeline, so it goes first
+			chain = append([]string{filter}, chain...)
+			cuts = found
+		}
+	}
+	if len(chain) == 0 {
+		return original
+	}
+
+	processed := filepath.Join(cfg.TempDir, job.ID+"_processed.wav")
+	cmd := exec.Command("ffmpeg",
+		"-i", audioFile,
+		"-af", strings.Join(chain, ","),
+		"-ac", "1",
+		"-ar", "16000",
+		"-f", "wav",
+		processed,
+		"-y")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	metrics.observeToolExit("ffmpeg", err)
+	if err != nil {
+		logger.Warn("Audio pre-processing failed, transcribing the original", "error", err, "output", outputTail(stderr.String()))
+		os.Remove(processed)
+		return original
+	}
+
+	removed := 0.0
+	for _, c := range cuts {
+		removed += c.end - c.start
+	}
+	logger.Info("Pre-processed audio", "filters", strings.Join(filters, ","), "silences_removed", len(cuts), "seconds_removed", removed)
+	return preparedAudio{path: processed, cuts: cuts}
+}
+
+// remove deletes the processed copy once it is no longer needed.
+func (a preparedAudio) remove(original string) {
+	if a.path != original {
+		os.Remove(a.path)
+	}
+}
+
+var (
+	silenceStartRe = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end: ([0-9.]+)`)
+)
+
+// silenceFilter finds long silences in audioFile and returns a filter that
+// drops them along with the cuts it makes. The filter is empty when there
+// is nothing to trim.
+func silenceFilter(audioFile string) (string, []audioCut, error) {
+	wav, err := openWAV(audioFile)
+	if err != nil {
+		return "", nil, err
+	}
+	duration := wav.duration()
+	rate := wav.sampleRate
+	wav.Close()
+
+	cmd := exec.Command("ffmpeg",
+		"-i", audioFile,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%g", silenceNoise, minSilence),
+		"-f", "null",
+		"-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	metrics.observeToolExit("ffmpeg", err)
+	if err != nil {
+		return "", nil, fmt.Errorf("ffmpeg silencedetect failed: %v", err)
+	}
+
+	frame := float64(trimFrameSamples) / float64(rate)
+	cuts := silenceCuts(parseSilences(stderr.String(), duration), frame)
+	if len(cuts) == 0 {
+		return "", nil, nil
+	}
+
+	terms := make([]string, len(cuts))
+	for i, c := range cuts {
+		from := int64(math.Round(c.start / frame))
+		to := int64(math.Round(c.end/frame)) - 1
+		terms[i] = fmt.Sprintf("between(n,%d,%d)", from, to)
+	}
+	filter := fmt.Sprintf("asetnsamples=n=%d,aselect='not(%s)',asetpts=N/SR/TB",
+		trimFrameSamples, strings.Join(terms, "+"))
+	return filter, cuts, nil
+}
+
+// parseSilences reads the silences reported by ffmpeg's silencedetect. A
+// silence still open at the end of the output lasts until duration.
+func parseSilences(output string, duration float64) []audioCut {
+	var silences []audioCut
+	open := -1.0
+	for _, line := range strings.Split(output, "\n") {
+		if m := silenceStartRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[1], 64)
+			open = max(v, 0)
+		} else if m := silenceEndRe.FindStringSubmatch(line); m != nil && open >= 0 {
+			v, _ := strconv.ParseFloat(m[1], 64)
+			silences = append(silences, audioCut{start: open, end: v})
+			open = -1
+		}
+	}
+	if open >= 0 && open < duration {
+		silences = append(silences, audioCut{start: open, end: duration})
+	}
+	return silences
+}
+
+// silenceCuts shrinks silences by the margin and aligns them to whole frames.
+func silenceCuts(silences []audioCut, frame float64) []audioCut {
+	var cuts []audioCut
+	for _, s := range silences {
+		// The epsilon keeps float error from costing a whole frame
+		start := math.Ceil((s.start+silenceMargin)/frame-1e-6) * frame
+		end := math.Floor((s.end-silenceMargin)/frame+1e-6) * frame
+		if end-start >= frame {
+			cuts = append(cuts, audioCut{start: start, end: end})
+		}
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i].start < cuts[j].start })
+	return cuts
+}
+
+// originalTime maps a time in the trimmed audio back to the original. A time
+// right at a cut belongs after the removed silence when it starts something
+// and before it when it ends something.
+func originalTime(t float64, cuts []audioCut, isEnd bool) float64 {
+	removed := 0.0
+	for _, c := range cuts {
+		at := t + removed
+		if at < c.start || (isEnd && at <= c.start) {
+			break
+		}
+		removed += c.end - c.start
+	}
+	return t + removed
+}
+
+// restoreTimeline moves every time recorded so far from the trimmed audio
+// back onto the original, so segments line up with what is played back.
+func restoreTimeline(job *Job, tr *Transcript, cuts []audioCut) {
+	if len(cuts) == 0 {
+		return
+	}
+	for i := range tr.Segments {
+		seg := &tr.Segments[i]
+		seg.Start = originalTime(seg.Start, cuts, false)
+		seg.End = originalTime(seg.End, cuts, true)
+		for j := range seg.Words {
+			seg.Words[j].Start = originalTime(seg.Words[j].Start, cuts, false)
+			seg.Words[j].End = originalTime(seg.Words[j].End, cuts, true)
+		}
+	}
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	for i := range job.QualityWarnings {
+		w := &job.QualityWarnings[i]
+		w.Start = originalTime(w.Start, cuts, false)
+		w.End = originalTime(w.End, cuts, true)
+	}
+	for i := range job.ChunkLanguages {
+		d := &job.ChunkLanguages[i]
+		d.Start = originalTime(d.Start, cuts, false)
+		d.End = originalTime(d.End, cuts, true)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSilencesAndCuts(t *testing.T) {
+	output := `[silencedetect @ 0x5581] silence_start: -0.01
+[silencedetect @ 0x5581] silence_end: 3.5 | silence_duration: 3.51
+size=N/A time=00:01:00.00 bitrate=N/A speed= 512x
+[silencedetect @ 0x5581] silence_start: 20
+[silencedetect @ 0x5581] silence_end: 22.4 | silence_duration: 2.4
+[silencedetect @ 0x5581] silence_start: 57.2`
+
+	silences := parseSilences(output, 60)
+	if len(silences) != 3 || silences[0].start != 0 || silences[2].end != 60 {
+		t.Fatalf("Unexpected silences %+v", silences)
+	}
+
+	cuts := silenceCuts(silences, 0.01)
+	want := []audioCut{{0.3, 3.2}, {20.3, 22.1}, {57.5, 59.7}}
+	if len(cuts) != len(want) {
+		t.Fatalf("Expected %d cuts, got %+v", len(want), cuts)
+	}
+	for i := range want {
+		if math.Abs(cuts[i].start-want[i].start) > 1e-9 || math.Abs(cuts[i].end-want[i].end) > 1e-9 {
+			t.Errorf("Cut %d: expected %+v, got %+v", i, want[i], cuts[i])
+		}
+	}
+}
+
+func TestRestoreTimeline(t *testing.T) {
+	// 10 s removed at 5 s and 5 s removed at 30 s of the original
+	cuts := []audioCut{{5, 15}, {30, 35}}
+	tr := &Transcript{Segments: []Segment{
+		{Start: 0, End: 5, Text: "Before.", Words: []Word{{Start: 1, End: 5, Word: "Before."}}},
+		{Start: 5, End: 20, Text: "Between."},
+		{Start: 20, End: 30, Text: "After."},
+	}}
+	job := &Job{QualityWarnings: []QualityWarning{{Kind: warnLowWPM, Start: 20, End: 30}}}
+	restoreTimeline(job, tr, cuts)
+
+	want := [][2]float64{{0, 5}, {15, 30}, {35, 45}}
+	for i, seg := range tr.Segments {
+		if seg.Start != want[i][0] || seg.End != want[i][1] {
+			t.Errorf("Segment %d: expected %v, got %.1f-%.1f", i, want[i], seg.Start, seg.End)
+		}
+	}
+	if w := tr.Segments[0].Words[0]; w.End != 5 {
+		t.Errorf("Expected the word to end before the cut, got %+v", w)
+	}
+	if w := job.QualityWarnings[0]; w.Start != 35 || w.End != 45 {
+		t.Errorf("Expected the warning to move with the audio, got %+v", w)
+	}
+}
+
+func TestPresetValidation(t *testing.T) {
+	c := defaultConfig()
+	c.fillDerived()
+	c.AudioPresets = map[string][]string{"podcast": {"highpass", "loudnorm"}}
+	c.AudioPreset = "podcast"
+	if err := c.validate(); err != nil {
+		t.Fatalf("Expected custom preset to validate: %v", err)
+	}
+	if filters, err := c.presetFilters("denoise"); err != nil || len(filters) != 1 {
+		t.Errorf("Expected a filter to work as a preset, got %v %v", filters, err)
+	}
+
+	c.AudioPresets["broken"] = []string{"reverb"}
+	if err := c.validate(); err == nil {
+		t.Error("Expected unknown filter to fail validation")
+	}
+	delete(c.AudioPresets, "broken")
+	c.AudioPreset = "studio"
+	if err := c.validate(); err == nil {
+		t.Error("Expected unknown default preset to fail validation")
+	}
+}
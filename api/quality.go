@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// QualityWarning flags a stretch of the transcript that whisper probably got
+// wrong: a repetition loop, text over audio whisper itself thought was not
+// speech, a chunk with no text at all, suspiciously few words per minute or
+// a chunk in a different language than the rest.
+type QualityWarning struct {
+	Kind   string  `json:"kind"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Detail string  `json:"detail,omitempty"`
+	// Rerun is set when the stretch was transcribed again with the quality
+	// model, Resolved when that made the problem go away.
+	Rerun    bool `json:"rerun,omitempty"`
+	Resolved bool `json:"resolved,omitempty"`
+}
+
+const (
+	warnRepetition = "repetition"
+	warnNoSpeech   = "no_speech"
+	warnEmpty      = "empty"
+	warnLowWPM     = "low_wpm"
+	warnLanguage   = "language"
+)
+
+const (
+	// A phrase of up to maxLoopPhrase words repeated minLoopRepeats times in
+	// a row, covering at least minLoopWords words, is a loop.
+	maxLoopPhrase  = 6
+	minLoopRepeats = 3
+	minLoopWords   = 6
+
+	// Windows shorter than this are too short to judge the speaking rate
+	minWPMSeconds = 30
+)
+
+// qualityWindow is a stretch of audio transcribed in one whisper run: a chunk
+// for chunked jobs, otherwise the whole file. Index is -1 for the latter.
+type qualityWindow struct {
+	Index      int
+	Start, End float64
+}
+
+func qualityWindows(audioFile string) []qualityWindow {
+	duration := wavSeconds(audioFile)
+	info, err := os.Stat(audioFile)
+	if err != nil || info.Size() <= cfg.ChunkThresholdBytes {
+		return []qualityWindow{{Index: -1, Start: 0, End: duration}}
+	}
+	var windows []qualityWindow
+	for i := 0; float64(i*cfg.ChunkSeconds) < duration; i++ {
+		start := float64(i * cfg.ChunkSeconds)
+		windows = append(windows, qualityWindow{Index: i, Start: start, End: min(start+float64(cfg.ChunkSeconds), duration)})
+	}
+	return windows
+}
+
+// windowSegments returns the segments whose midpoint falls in w.
+func windowSegments(segments []Segment, w qualityWindow) []Segment {
+	var out []Segment
+	for _, seg := range segments {
+		mid := (seg.Start + seg.End) / 2
+		if w.Index < 0 || (mid >= w.Start && mid < w.End) {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// checkWindow returns the quality warnings for the segments of one window.
+func checkWindow(w qualityWindow, segments []Segment) []QualityWarning {
+	words := 0
+	for _, seg := range segments {
+		words += len(strings.Fields(seg.Text))
+	}
+	if words == 0 {
+		return []QualityWarning{{Kind: warnEmpty, Start: w.Start, End: w.End, Detail: "no text was transcribed"}}
+	}
+
+	warnings := findRepetitions(segments)
+	for _, seg := range segments {
+		if seg.NoSpeechProb > cfg.NoSpeechThreshold && strings.TrimSpace(seg.Text) != "" {
+			warnings = append(warnings, QualityWarning{
+				Kind:   warnNoSpeech,
+				Start:  seg.Start,
+				End:    seg.End,
+				Detail: fmt.Sprintf("no-speech probability %.2f", seg.NoSpeechProb),
+			})
+		}
+	}
+	if minutes := (w.End - w.Start) / 60; w.End-w.Start >= minWPMSeconds && cfg.MinWordsPerMinute > 0 {
+		if wpm := float64(words) / minutes; wpm < cfg.MinWordsPerMinute {
+			warnings = append(warnings, QualityWarning{
+				Kind:   warnLowWPM,
+				Start:  w.Start,
+				End:    w.End,
+				Detail: fmt.Sprintf("%.0f words per minute", wpm),
+			})
+		}
+	}
+	return warnings
+}
+
+// loopToken is a normalized word and the segment it came from.
+type loopToken struct {
+	word    string
+	segment int
+}
+
+// findRepetitions finds phrases that repeat back to back, the typical
+// whisper failure on silence or music ("Thank you. Thank you. Thank you.").
+func findRepetitions(segments []Segment) []QualityWarning {
+	var tokens []loopToken
+	for i, seg := range segments {
+		for _, f := range strings.Fields(seg.Text) {
+			word := strings.ToLower(strings.TrimFunc(f, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			}))
+			if word != "" {
+				tokens = append(tokens, loopToken{word: word, segment: i})
+			}
+		}
+	}
+
+	var warnings []QualityWarning
+	for i := 0; i < len(tokens); {
+		bestN, bestRepeats := 0, 0
+		for n := 1; n <= maxLoopPhrase && i+n <= len(tokens); n++ {
+			repeats := 1
+			for start := i + n; start+n <= len(tokens) && samePhrase(tokens[i:i+n], tokens[start:start+n]); start += n {
+				repeats++
+			}
+			if repeats >= minLoopRepeats && n*repeats >= minLoopWords && n*repeats > bestN*bestRepeats {
+				bestN, bestRepeats = n, repeats
+			}
+		}
+		if bestN == 0 {
+			i++
+			continue
+		}
+
+		last := i + bestN*bestRepeats - 1
+		phrase := make([]string, bestN)
+		for j := range phrase {
+			phrase[j] = tokens[i+j].word
+		}
+		warnings = append(warnings, QualityWarning{
+			Kind:   warnRepetition,
+			Start:  segments[tokens[i].segment].Start,
+			End:    segments[tokens[last].segment].End,
+			Detail: fmt.Sprintf("%q repeated %d times", strings.Join(phrase, " "), bestRepeats),
+		})
+		i = last + 1
+	}
+	return warnings
+}
+
+func samePhrase(a, b []loopToken) bool {
+	for i := range a {
+		if a[i].word != b[i].word {
+			return false
+		}
+	}
+	return true
+}
+
+// checkQuality runs the quality-check stage on the raw whisper output. When
+// a quality model is configured, windows with warnings are transcribed again
+// with it and the new text is kept if it has fewer problems.
+func checkQuality(job *Job, logger *slog.Logger, audioFile string, tr *Transcript) {
+	logger = logger.With("stage", "quality")
+	defer metrics.observeStage("quality", time.Now())
+
+	var warnings []QualityWarning
+	var rerun []qualityWindow
+	for _, w := range qualityWindows(audioFile) {
+		found := checkWindow(w, windowSegments(tr.Segments, w))
+		if len(found) > 0 && cfg.QualityModel != "" && cfg.QualityModel != cfg.Model {
+			rerun = append(rerun, w)
+			continue
+		}
+		warnings = append(warnings, found...)
+	}
+
+	if len(rerun) > 0 {
+		updateJobStatusDetailed(job, "retranscribing", 80, 100, 90, "")
+		opts := whisperOptions{model: cfg.QualityModel, prompt: jobPrompt(job)}
+		if cfg.ForceLanguage {
+			jobsMu.RLock()
+			opts.language = job.DetectedLanguage
+			jobsMu.RUnlock()
+		}
+		for _, w := range rerun {
+			old := checkWindow(w, windowSegments(tr.Segments, w))
+			warnings = append(warnings, rerunWindow(logger, audioFile, opts, w, tr, old)...)
+		}
+	}
+
+	jobsMu.Lock()
+	// Language warnings come from the language detection stage
+	for _, w := range job.QualityWarnings {
+		if w.Kind == warnLanguage {
+			warnings = append(warnings, w)
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Start < warnings[j].Start })
+	job.QualityWarnings = warnings
+	jobsMu.Unlock()
+
+	if len(warnings) > 0 {
+		logger.Warn("Transcript has quality warnings", "warnings", len(warnings), "rerun_windows", len(rerun))
+	}
+}
+
+// rerunWindow transcribes one window with the quality model and swaps its
+// segments into tr if that reduces the warnings. It returns the warnings to
+// record for the window.
+func rerunWindow(logger *slog.Logger, audioFile string, opts whisperOptions, w qualityWindow, tr *Transcript, old []QualityWarning) []QualityWarning {
+	logger = logger.With("window_start", w.Start, "model", cfg.QualityModel)
+	markRerun := func(warnings []QualityWarning) []QualityWarning {
+		for i := range warnings {
+			warnings[i].Rerun = true
+		}
+		return warnings
+	}
+
+	file := audioFile
+	if w.Index >= 0 {
+		chunk, err := splitAudioChunk(audioFile, w.Index)
+		if err != nil {
+			logger.Warn("Failed to extract audio for re-transcription", "error", err)
+			return old
+		}
+		defer os.Remove(chunk)
+		file = chunk
+	}
+
+	part, err := transcribeAudioDirect(logger, file, opts)
+	if err != nil {
+		logger.Warn("Re-transcription failed, keeping original text", "error", err)
+		return markRerun(old)
+	}
+	part.shift(w.Start)
+
+	found := checkWindow(w, part.Segments)
+	if len(found) >= len(old) {
+		logger.Info("Re-transcription did not improve the window, keeping original text")
+		return markRerun(old)
+	}
+
+	replaceWindow(tr, w, part.Segments)
+	logger.Info("Replaced window with re-transcription", "warnings_before", len(old), "warnings_after", len(found))
+
+	remaining := make(map[string]bool)
+	for _, f := range found {
+		remaining[f.Kind] = true
+	}
+	for i := range old {
+		old[i].Resolved = !remaining[old[i].Kind]
+	}
+	var out []QualityWarning
+	for _, o := range old {
+		if o.Resolved {
+			out = append(out, o)
+		}
+	}
+	return markRerun(append(out, found...))
+}
+
+// replaceWindow swaps the segments of w for segments and renumbers the
+// transcript.
+func replaceWindow(tr *Transcript, w qualityWindow, segments []Segment) {
+	var kept []Segment
+	if w.Index >= 0 {
+		for _, seg := range tr.Segments {
+			mid := (seg.Start + seg.End) / 2
+			if mid < w.Start || mid >= w.End {
+				kept = append(kept, seg)
+			}
+		}
+	}
+	kept = append(kept, segments...)
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Start < kept[j].Start })
+	for i := range kept {
+		kept[i].ID = i
+	}
+	tr.Segments = kept
+	tr.Text = joinSegmentText(kept)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindRepetitions(t *testing.T) {
+	segments := []Segment{
+		{Start: 0, End: 5, Text: "So that is the plan for today."},
+		{Start: 5, End: 7, Text: "Thank you. Thank you."},
+		{Start: 7, End: 9, Text: "Thank you. Thank you."},
+		{Start: 9, End: 12, Text: "It was very, very good."},
+	}
+	warnings := findRepetitions(segments)
+	if len(warnings) != 1 {
+		t.Fatalf("Expected one loop, got %+v", warnings)
+	}
+	w := warnings[0]
+	if w.Kind != warnRepetition || w.Start != 5 || w.End != 9 || !strings.Contains(w.Detail, `"thank you" repeated 4 times`) {
+		t.Errorf("Unexpected warning %+v", w)
+	}
+}
+
+func TestCheckWindow(t *testing.T) {
+	window := qualityWindow{Index: 2, Start: 240, End: 360}
+
+	if got := checkWindow(window, nil); len(got) != 1 || got[0].Kind != warnEmpty {
+		t.Errorf("Expected an empty warning, got %+v", got)
+	}
+
+	segments := []Segment{
+		{Start: 250, End: 254, Text: "Subscribe to my channel.", NoSpeechProb: 0.92},
+		{Start: 300, End: 304, Text: "And then we left.", NoSpeechProb: 0.1},
+	}
+	got := checkWindow(window, segments)
+	kinds := make(map[string]bool)
+	for _, w := range got {
+		kinds[w.Kind] = true
+	}
+	if len(got) != 2 || !kinds[warnNoSpeech] || !kinds[warnLowWPM] {
+		t.Errorf("Expected no-speech and low WPM warnings, got %+v", got)
+	}
+}
+
+func TestReplaceWindow(t *testing.T) {
+	tr := &Transcript{Segments: []Segment{
+		{Start: 0, End: 100, Text: "First chunk."},
+		{Start: 120, End: 130, Text: "Thank you."},
+		{Start: 130, End: 140, Text: "Thank you."},
+		{Start: 240, End: 250, Text: "Third chunk."},
+	}}
+	replaceWindow(tr, qualityWindow{Index: 1, Start: 120, End: 240}, []Segment{{Start: 121, End: 200, Text: "Second chunk."}})
+
+	if tr.Text != "First chunk. Second chunk. Third chunk." {
+		t.Errorf("Unexpected text %q", tr.Text)
+	}
+	for i, seg := range tr.Segments {
+		if seg.ID != i {
+			t.Errorf("Expected segment %d to be renumbered, got %d", i, seg.ID)
+		}
+	}
+}
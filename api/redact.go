@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RedactionRule is a custom detector from the redaction_rules setting.
+// Matches are masked as [NAME].
+type RedactionRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// RedactionReport records what the redaction stage masked. It never holds
+// the redacted text itself.
+type RedactionReport struct {
+	Counts       map[string]int `json:"counts"`
+	Items        []RedactedItem `json:"items,omitempty"`
+	AudioBleeped bool           `json:"audio_bleeped,omitempty"`
+}
+
+// RedactedItem locates one masked match. Segment is -1 when the job has no
+// segments, in which case there are no times either.
+type RedactedItem struct {
+	Kind    string  `json:"kind"`
+	Segment int     `json:"segment"`
+	Start   float64 `json:"start,omitempty"`
+	End     float64 `json:"end,omitempty"`
+}
+
+// detector finds one kind of personal data. validate, when set, filters
+// regexp matches that only look right, such as digit runs that fail the
+// card checksum.
+type detector struct {
+	kind     string
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+// Built-in detectors in priority order: when matches overlap, the earlier
+// detector wins.
+var builtinDetectors = []detector{
+	{
+		kind:    "email",
+		pattern: regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9\-]+(?:\.[a-z0-9\-]+)*\.[a-z]{2,}`),
+	},
+	{
+		kind:     "card",
+		pattern:  regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		validate: func(m string) bool { return luhnValid(digitsOf(m)) },
+	},
+	{
+		kind:    "phone",
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s.\-]?)?(?:\(\d{1,4}\)[\s.\-]?)?\d{2,4}(?:[\s.\-]?\d{2,4}){1,4}`),
+		validate: func(m string) bool {
+			n := len(digitsOf(m))
+			return n >= 7 && n <= 15
+		},
+	},
+	{
+		kind: "address",
+		pattern: regexp.MustCompile(`(?i)\b\d{1,5}\s+(?:[a-z0-9.'\-]+\s+){1,4}` +
+			`(?:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|way|place|pl|terrace|parkway|pkwy|square|sq)\b\.?`),
+	},
+}
+
+// compileRedactionRules turns the configured rules into detectors.
+func compileRedactionRules(rules []RedactionRule) ([]detector, error) {
+	var detectors []detector
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redaction rule %q has no name", rule.Pattern)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %s: %v", rule.Name, err)
+		}
+		detectors = append(detectors, detector{kind: strings.ToLower(rule.Name), pattern: re})
+	}
+	return detectors, nil
+}
+
+func digitsOf(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// luhnValid reports whether digits form a plausible card number.
+func luhnValid(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// piiMatch is a detected range of text.
+type piiMatch struct {
+	kind       string
+	start, end int
+}
+
+// findPII runs all detectors over text and returns non-overlapping matches
+// in text order.
+func findPII(text string, detectors []detector) []piiMatch {
+	var found []piiMatch
+	for _, d := range detectors {
+		for _, m := range d.pattern.FindAllStringIndex(text, -1) {
+			if d.validate != nil && !d.validate(text[m[0]:m[1]]) {
+				continue
+			}
+			overlaps := false
+			for _, f := range found {
+				if m[0] < f.end && f.start < m[1] {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				found = append(found, piiMatch{kind: d.kind, start: m[0], end: m[1]})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+	return found
+}
+
+func maskFor(kind string) string {
+	return "[" + strings.ToUpper(kind) + "]"
+}
+
+// maskText replaces matches with their masks.
+func maskText(text string, matches []piiMatch) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.start])
+		b.WriteString(maskFor(m.kind))
+		last = m.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// redactSegment masks a segment and returns the audio range of every match.
+// When the segment's words line up with its text the range is that of the
+// matched words, otherwise the whole segment.
+func redactSegment(seg *Segment, matches []piiMatch) [][2]float64 {
+	fields := fieldOffsets(seg.Text)
+	aligned := len(seg.Words) > 0 && len(fields) == len(seg.Words)
+
+	var ranges [][2]float64
+	var words []Word
+	nextWord := 0
+	for _, m := range matches {
+		if !aligned {
+			ranges = append(ranges, [2]float64{seg.Start, seg.End})
+			continue
+		}
+		first, last := -1, -1
+		for i, f := range fields {
+			if f[0] < m.end && m.start < f[1] {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		ranges = append(ranges, [2]float64{seg.Words[first].Start, seg.Words[last].End})
+		words = append(words, seg.Words[nextWord:first]...)
+		words = append(words, Word{Start: seg.Words[first].Start, End: seg.Words[last].End, Word: maskFor(m.kind)})
+		nextWord = last + 1
+	}
+
+	if aligned {
+		seg.Words = append(words, seg.Words[nextWord:]...)
+	} else {
+		seg.Words = nil
+	}
+	seg.Text = maskText(seg.Text, matches)
+	return ranges
+}
+
+// fieldOffsets returns the byte ranges of the whitespace-separated fields
+// of s.
+func fieldOffsets(s string) [][2]int {
+	var fields [][2]int
+	start := -1
+	for i, r := range s {
+		space := r == ' ' || r == '\t' || r == '\n' || r == '\r'
+		if !space && start < 0 {
+			start = i
+		} else if space && start >= 0 {
+			fields = append(fields, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, [2]int{start, len(s)})
+	}
+	return fields
+}
+
+// redactTranscript runs the optional redaction stage: it masks personal data
+// in the transcript, records a report and, if requested, bleeps the matching
+// ranges of the served WAV.
+func redactTranscript(job *Job, logger *slog.Logger, tr *Transcript) {
+	jobsMu.RLock()
+	wanted, bleep := job.Redact, job.BleepAudio
+	jobsMu.RUnlock()
+	if !wanted {
+		return
+	}
+
+	logger = logger.With("stage", "redact")
+	updateJobStatusDetailed(job, "redacting", 82, 100, 90, "")
+	defer metrics.observeStage("redact", time.Now())
+
+	// Custom rules were validated with the configuration
+	custom, _ := compileRedactionRules(cfg.RedactionRules)
+	detectors := append(append([]detector(nil), builtinDetectors...), custom...)
+
+	report := &RedactionReport{Counts: make(map[string]int)}
+	var ranges [][2]float64
+	if len(tr.Segments) > 0 {
+		for i := range tr.Segments {
+			matches := findPII(tr.Segments[i].Text, detectors)
+			if len(matches) == 0 {
+				continue
+			}
+			segRanges := redactSegment(&tr.Segments[i], matches)
+			for j, m := range matches {
+				report.Counts[m.kind]++
+				item := RedactedItem{Kind: m.kind, Segment: i}
+				if j < len(segRanges) {
+					item.Start, item.End = segRanges[j][0], segRanges[j][1]
+				}
+				report.Items = append(report.Items, item)
+			}
+			ranges = append(ranges, segRanges...)
+		}
+		tr.Text = joinSegmentText(tr.Segments)
+	} else {
+		matches := findPII(tr.Text, detectors)
+		for _, m := range matches {
+			report.Counts[m.kind]++
+			report.Items = append(report.Items, RedactedItem{Kind: m.kind, Segment: -1})
+		}
+		tr.Text = maskText(tr.Text, matches)
+	}
+
+	if bleep && len(ranges) > 0 {
+		audioPath := filepath.Join(cfg.DataDir, job.ID+".wav")
+		if err := bleepWAV(audioPath, ranges); err != nil {
+			logger.Error("Failed to bleep audio", "error", err)
+		} else {
+			report.AudioBleeped = true
+		}
+	}
+
+	jobsMu.Lock()
+	job.Redactions = report
+	jobsMu.Unlock()
+	logger.Info("Redacted transcript", "matches", len(report.Items), "audio_bleeped", report.AudioBleeped)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPII(t *testing.T) {
+	custom, err := compileRedactionRules([]RedactionRule{{Name: "ticket", Pattern: `TCK-\d+`}})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	detectors := append(append([]detector(nil), builtinDetectors...), custom...)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"mail jane.doe@example.co.uk today", "mail [EMAIL] today"},
+		{"card 4111 1111 1111 1111 please", "card [CARD] please"},
+		{"order 4111 1111 1111 1112 shipped", "order 4111 1111 1111 1112 shipped"},
+		{"call +1 (555) 123-4567 now", "call [PHONE] now"},
+		{"in 2024 we had 300 users", "in 2024 we had 300 users"},
+		{"I live at 221 Baker Street in London", "I live at [ADDRESS] in London"},
+		{"see TCK-1234 for details", "see [TICKET] for details"},
+	}
+	for _, tt := range tests {
+		if got := maskText(tt.text, findPII(tt.text, detectors)); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestRedactTranscript(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	c.DataDir = t.TempDir()
+	cfg = &c
+
+	const rate = 16000
+	audio := filepath.Join(c.DataDir, "redact-test.wav")
+	if err := writeWAV(audio, make([]float32, 4*rate), rate); err != nil {
+		t.Fatal(err)
+	}
+
+	job := &Job{ID: "redact-test", Redact: true, BleepAudio: true}
+	tr := &Transcript{Segments: []Segment{
+		{Start: 0, End: 2, Text: "Nothing here."},
+		{Start: 2, End: 4, Text: "Write to bob@example.com please.", Words: []Word{
+			{Start: 2, End: 2.3, Word: "Write"},
+			{Start: 2.3, End: 2.5, Word: "to"},
+			{Start: 2.5, End: 3.2, Word: "bob@example.com"},
+			{Start: 3.2, End: 3.6, Word: "please."},
+		}},
+	}}
+	redactTranscript(job, slog.Default(), tr)
+
+	if tr.Text != "Nothing here. Write to [EMAIL] please." {
+		t.Errorf("Unexpected text %q", tr.Text)
+	}
+	if w := tr.Segments[1].Words; len(w) != 4 || w[2].Word != "[EMAIL]" {
+		t.Errorf("Expected the masked word to replace the email, got %+v", w)
+	}
+	r := job.Redactions
+	if r == nil || r.Counts["email"] != 1 || !r.AudioBleeped || len(r.Items) != 1 {
+		t.Fatalf("Unexpected report %+v", r)
+	}
+	if item := r.Items[0]; item.Segment != 1 || item.Start != 2.5 || item.End != 3.2 {
+		t.Errorf("Unexpected item %+v", item)
+	}
+
+	wav, err := openWAV(audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wav.Close()
+	for _, tc := range []struct {
+		start, end float64
+		bleeped    bool
+	}{{0, 2.5, false}, {2.5, 3.2, true}, {3.2, 4, false}} {
+		samples, _ := wav.readRange(tc.start, tc.end)
+		peak := float32(0)
+		for _, s := range samples {
+			peak = max(peak, s, -s)
+		}
+		if (peak > 0.1) != tc.bleeped {
+			t.Errorf("Range %.1f-%.1f: expected bleeped=%v, peak %.2f", tc.start, tc.end, tc.bleeped, peak)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// retryPolicy controls how often a class of errors is retried and how long to
+// wait before the first retry. The delay doubles with every further retry.
+type retryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// maxBackoff caps the exponential backoff between two attempts.
+const maxBackoff = 10 * time.Minute
+
+// retryPolicy returns the configured policy for a class of errors. Invalid
+// input, unsupported videos and internal errors are never retried.
+func (c *Config) retryPolicy(class errorClass) retryPolicy {
+	switch class {
+	case classTransientDownload:
+		return retryPolicy{MaxRetries: c.DownloadRetries, Backoff: time.Duration(c.DownloadBackoff) * time.Second}
+	case classEngineCrash:
+		return retryPolicy{MaxRetries: c.EngineRetries, Backoff: time.Duration(c.EngineBackoff) * time.Second}
+	default:
+		return retryPolicy{}
+	}
+}
+
+// backoffDelay returns the wait before the given retry (0-based).
+func backoffDelay(base time.Duration, retry int) time.Duration {
+	delay := base
+	for i := 0; i < retry && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// JobAttempt records a failed attempt of one pipeline stage.
+type JobAttempt struct {
+	Stage      string     `json:"stage"`
+	ErrorClass errorClass `json:"error_class"`
+	ErrorCode  errorCode  `json:"error_code"`
+	Error      string     `json:"error"`
+	Time       time.Time  `json:"time"`
+}
+
+// retryLater is returned by withRetry when a stage failed but may be retried.
+// The job gives up the worker and is queued again once delay has passed.
+type retryLater struct {
+	err   error
+	delay time.Duration
+}
+
+func (e *retryLater) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryLater) Unwrap() error {
+	return e.err
+}
+
+// withRetry runs fn once. Every failure is recorded on the job under stage
+// and logged to logger; when the policy of the error's class allows another
+// attempt, the error is wrapped in a retryLater for scheduleRetry. Retries
+// are counted per stage on the job, so they carry over to the next run.
+func withRetry(job *Job, logger *slog.Logger, stage string, fn func() error) error {
+	err := fn()
+
+	jobsMu.Lock()
+	retry := job.StageRetries[stage]
+	delete(job.StageRetries, stage)
+	jobsMu.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	code, _ := errorDetails(err)
+	class := classifyError(err)
+	policy := cfg.retryPolicy(class)
+	willRetry := retry < policy.MaxRetries
+
+	jobsMu.Lock()
+	job.Attempts = append(job.Attempts, JobAttempt{
+		Stage:      stage,
+		ErrorClass: class,
+		ErrorCode:  code,
+		Error:      err.Error(),
+		Time:       time.Now(),
+	})
+	if willRetry {
+		job.RetryCount++
+		if job.StageRetries == nil {
+			job.StageRetries = make(map[string]int)
+		}
+		job.StageRetries[stage] = retry + 1
+	}
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+
+	if !willRetry {
+		return err
+	}
+
+	delay := backoffDelay(policy.Backoff, retry)
+	logger.Warn("Stage failed, retrying", "error_class", class, "error", err,
+		"retry", retry+1, "max_retries", policy.MaxRetries, "delay", delay.String())
+	return &retryLater{err: err, delay: delay}
+}
+
+// isRetryLater reports whether err asks for the job to be retried later.
+func isRetryLater(err error) bool {
+	var later *retryLater
+	return errors.As(err, &later)
+}
+
+// scheduleRetry re-queues a job whose stage failed with a retryLater error
+// after the backoff delay, leaving the worker free for other jobs meanwhile.
+// It reports false for other errors, which the caller handles.
+func scheduleRetry(job *Job, logger *slog.Logger, err error) bool {
+	var later *retryLater
+	if !errors.As(err, &later) {
+		return false
+	}
+
+	next := time.Now().Add(later.delay)
+	jobsMu.Lock()
+	job.Status = "retrying"
+	job.NextRetry = &next
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+
+	logger.Info("Job re-queued for retry", "stage", "queue", "at", next.Format(time.RFC3339))
+	time.AfterFunc(later.delay, func() {
+		jobQueue <- queuedJob{ID: job.ID, Resume: true}
+	})
+	return true
+}
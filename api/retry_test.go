@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	if got := backoffDelay(5*time.Second, 0); got != 5*time.Second {
+		t.Errorf("Expected 5s for first retry, got %v", got)
+	}
+	if got := backoffDelay(5*time.Second, 2); got != 20*time.Second {
+		t.Errorf("Expected 20s for third retry, got %v", got)
+	}
+	if got := backoffDelay(time.Minute, 20); got != maxBackoff {
+		t.Errorf("Expected backoff to be capped at %v, got %v", maxBackoff, got)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	c.EngineRetries = 2
+	c.EngineBackoff = 0
+	cfg = &c
+
+	// Each run of the stage is one call; the job is re-queued in between
+	job := &Job{ID: "retry-test"}
+	calls := 0
+	fn := func() error {
+		calls++
+		if calls < 3 {
+			return newPipelineError(codeOutOfMemory, "killed")
+		}
+		return nil
+	}
+	for run := 1; run <= 2; run++ {
+		err := withRetry(job, slog.Default(), "transcribe", fn)
+		if !isRetryLater(err) || classifyError(err) != classEngineCrash {
+			t.Fatalf("Expected run %d to ask for a retry, got %v", run, err)
+		}
+		if job.StageRetries["transcribe"] != run {
+			t.Errorf("Expected %d retries of the stage, got %d", run, job.StageRetries["transcribe"])
+		}
+	}
+	if err := withRetry(job, slog.Default(), "transcribe", fn); err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if job.RetryCount != 2 || len(job.Attempts) != 2 || len(job.StageRetries) != 0 {
+		t.Errorf("Expected 2 retries recorded, got count=%d attempts=%d pending=%v", job.RetryCount, len(job.Attempts), job.StageRetries)
+	}
+
+	job = &Job{ID: "retry-test-exhausted", StageRetries: map[string]int{"transcribe": 2}}
+	err := withRetry(job, slog.Default(), "transcribe", func() error {
+		return newPipelineError(codeEngineFailed, "crashed")
+	})
+	if err == nil || isRetryLater(err) {
+		t.Errorf("Expected the last retry to fail for good, got %v", err)
+	}
+	if len(job.StageRetries) != 0 {
+		t.Errorf("Expected retries to be reset after giving up, got %v", job.StageRetries)
+	}
+
+	job = &Job{ID: "retry-test-unsupported"}
+	calls = 0
+	err = withRetry(job, slog.Default(), "download", func() error {
+		calls++
+		return newPipelineError(codeVideoPrivate, "private video")
+	})
+	if classifyError(err) != classUnsupportedVideo {
+		t.Errorf("Expected unsupported video error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no retries for unsupported video, got %d calls", calls)
+	}
+	if classifyError(errors.New("plain")) != classInternal {
+		t.Error("Expected unclassified errors to be internal")
+	}
+}
+
+func TestScheduleRetry(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	cfg = &c
+
+	job := &Job{ID: "schedule-retry-test", Status: "transcribing"}
+	if scheduleRetry(job, slog.Default(), errors.New("plain")) {
+		t.Fatal("Expected errors without a retry to be left to the caller")
+	}
+
+	err := &retryLater{err: newPipelineError(codeDownloadFailed, "reset"), delay: 10 * time.Millisecond}
+	if !scheduleRetry(job, slog.Default(), err) {
+		t.Fatal("Expected the job to be re-queued")
+	}
+	if job.Status != "retrying" || job.NextRetry == nil {
+		t.Errorf("Expected a pending retry, got status %q next %v", job.Status, job.NextRetry)
+	}
+
+	select {
+	case item := <-jobQueue:
+		if item.ID != job.ID || !item.Resume {
+			t.Errorf("Expected the job to be resumed, got %+v", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Job was not re-queued")
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevisionInfo describes one version of a job's transcript. Revision 0 is
+// the machine output; every edit adds the next number.
+type RevisionInfo struct {
+	Number  int       `json:"number"`
+	Author  string    `json:"author"`
+	Note    string    `json:"note,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Revision is a full transcript version as stored on disk.
+type Revision struct {
+	RevisionInfo
+	Text     string    `json:"text"`
+	Segments []Segment `json:"segments,omitempty"`
+}
+
+// machineAuthor is the author recorded for the original transcript.
+const machineAuthor = "whisper"
+
+// revisionsMu serializes edits so revision numbers are never reused.
+var revisionsMu sync.Mutex
+
+func revisionPath(jobID string, number int) string {
+	return filepath.Join(cfg.JobsDir, jobID, "revisions", fmt.Sprintf("%04d.json", number))
+}
+
+func saveRevision(jobID string, rev *Revision) error {
+	path := revisionPath(jobID, rev.Number)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// storeRevision saves a revision for handleEditTranscript, replaced in tests
+// that change the job while an edit is being saved.
+var storeRevision = saveRevision
+
+// discardRevisions removes revision files written by an edit that could not
+// be applied, and the revisions directory if nothing else is left in it.
+func discardRevisions(jobID string, revs ...*Revision) {
+	for _, rev := range revs {
+		os.Remove(revisionPath(jobID, rev.Number))
+	}
+	dir := filepath.Join(cfg.JobsDir, jobID, "revisions")
+	if os.Remove(dir) == nil {
+		os.Remove(filepath.Dir(dir))
+	}
+}
+
+// loadRevision returns a stored revision. Revision 0 of a job that was never
+// edited is its current transcript. The caller holds jobsMu.
+func loadRevision(job *Job, number int) (*Revision, error) {
+	if number == 0 && len(job.Revisions) == 0 {
+		return &Revision{
+			RevisionInfo: RevisionInfo{Author: machineAuthor, Created: job.Created},
+			Text:         job.Text,
+			Segments:     job.Segments,
+		}, nil
+	}
+	if number < 0 || number >= len(job.Revisions) {
+		return nil, fmt.Errorf("revision %d does not exist", number)
+	}
+
+	data, err := os.ReadFile(revisionPath(job.ID, number))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %d: %v", number, err)
+	}
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("failed to parse revision %d: %v", number, err)
+	}
+	return &rev, nil
+}
+
+// latestRevision is the number of the revision in Job.Text and Job.Segments.
+func latestRevision(job *Job) int {
+	return max(len(job.Revisions)-1, 0)
+}
+
+// revisionJob returns a copy of job showing the given revision, for exports.
+// The caller holds jobsMu.
+func revisionJob(job *Job, number int) (*Job, error) {
+	if number == latestRevision(job) {
+		return job, nil
+	}
+	rev, err := loadRevision(job, number)
+	if err != nil {
+		return nil, err
+	}
+	view := *job
+	view.Text = rev.Text
+	view.Segments = rev.Segments
+	return &view, nil
+}
+
+// transcriptEdit is the body of PUT /job/{id}/transcript. Either Text or
+// Segments is given.
+type transcriptEdit struct {
+	Author   string    `json:"author"`
+	Note     string    `json:"note"`
+	Text     *string   `json:"text"`
+	Segments []Segment `json:"segments"`
+}
+
+// handleTranscript serves the transcript of a job and its revisions:
+//
+//	GET  /job/{id}/transcript[?revision=N]  a revision, the latest by default
+//	PUT  /job/{id}/transcript               save an edit as a new revision
+//	GET  /job/{id}/transcript/revisions     revision history
+//	GET  /job/{id}/transcript/diff?from=N&to=M
+func handleTranscript(w http.ResponseWriter, r *http.Request, id, sub string) {
+	setCORSHeaders(w, "GET, PUT, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == "PUT":
+		handleEditTranscript(w, r, id)
+	case r.Method != "GET":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case sub == "":
+		handleGetRevision(w, r, id)
+	case sub == "revisions":
+		handleListRevisions(w, id)
+	case sub == "diff":
+		handleDiffRevisions(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func handleGetRevision(w http.ResponseWriter, r *http.Request, id string) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	job, exists := jobs[id]
+	if !exists {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	number := latestRevision(job)
+	if v := r.URL.Query().Get("revision"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+		number = n
+	}
+
+	var rev *Revision
+	if number == latestRevision(job) && len(job.Revisions) > 0 {
+		rev = &Revision{RevisionInfo: job.Revisions[number], Text: job.Text, Segments: job.Segments}
+	} else {
+		var err error
+		if rev, err = loadRevision(job, number); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+func handleListRevisions(w http.ResponseWriter, id string) {
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	job, exists := jobs[id]
+	if !exists {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	revisions := job.Revisions
+	if len(revisions) == 0 {
+		revisions = []RevisionInfo{{Author: machineAuthor, Created: job.Created}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func handleDiffRevisions(w http.ResponseWriter, r *http.Request, id string) {
+	jobsMu.RLock()
+	job, exists := jobs[id]
+	if !exists {
+		jobsMu.RUnlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	to := latestRevision(job)
+	from := max(to-1, 0)
+	q := r.URL.Query()
+	var err error
+	if v := q.Get("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil {
+			jobsMu.RUnlock()
+			http.Error(w, "Invalid from revision", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			jobsMu.RUnlock()
+			http.Error(w, "Invalid to revision", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromView, err := revisionJob(job, from)
+	if err != nil {
+		jobsMu.RUnlock()
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	toView, err := revisionJob(job, to)
+	if err != nil {
+		jobsMu.RUnlock()
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	fromText, toText := fromView.Text, toView.Text
+	jobsMu.RUnlock()
+
+	chunks := diffWords(fromText, toText)
+	result := struct {
+		From     int         `json:"from"`
+		To       int         `json:"to"`
+		Inserted int         `json:"inserted_words"`
+		Deleted  int         `json:"deleted_words"`
+		Changes  []diffChunk `json:"changes"`
+	}{From: from, To: to, Changes: chunks}
+	for _, c := range chunks {
+		switch c.Op {
+		case "insert":
+			result.Inserted += len(strings.Fields(c.Text))
+		case "delete":
+			result.Deleted += len(strings.Fields(c.Text))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func handleEditTranscript(w http.ResponseWriter, r *http.Request, id string) {
+	var edit transcriptEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if (edit.Text == nil) == (edit.Segments == nil) {
+		http.Error(w, "Provide either text or segments", http.StatusBadRequest)
+		return
+	}
+	if edit.Segments != nil {
+		if err := validateSegments(edit.Segments); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	author := strings.TrimSpace(edit.Author)
+	if author == "" {
+		author = "anonymous"
+	}
+
+	revisionsMu.Lock()
+	defer revisionsMu.Unlock()
+
+	jobsMu.RLock()
+	job, exists := jobs[id]
+	if !exists {
+		jobsMu.RUnlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.Status != "done" {
+		jobsMu.RUnlock()
+		http.Error(w, "Job is not finished", http.StatusConflict)
+		return
+	}
+	revisions := append([]RevisionInfo(nil), job.Revisions...)
+	current := &Transcript{Text: job.Text, Segments: job.Segments}
+	created := job.Created
+	jobsMu.RUnlock()
+
+	// The machine output becomes revision 0 on the first edit
+	var written []*Revision
+	if len(revisions) == 0 {
+		original := &Revision{
+			RevisionInfo: RevisionInfo{Number: 0, Author: machineAuthor, Created: created},
+			Text:         current.Text,
+			Segments:     current.Segments,
+		}
+		if err := storeRevision(id, original); err != nil {
+			http.Error(w, "Failed to save revision", http.StatusInternalServerError)
+			return
+		}
+		revisions = append(revisions, original.RevisionInfo)
+		written = append(written, original)
+	}
+
+	updated := &Transcript{}
+	if edit.Segments != nil {
+		updated.Segments = edit.Segments
+		updated.Text = joinSegmentText(edit.Segments)
+	} else {
+		updated.Text = strings.TrimSpace(*edit.Text)
+		if len(current.Segments) > 0 {
+			updated.Segments = realignSegments(current.Segments, updated.Text)
+		}
+	}
+
+	rev := &Revision{
+		RevisionInfo: RevisionInfo{Number: len(revisions), Author: author, Note: edit.Note, Created: time.Now()},
+		Text:         updated.Text,
+		Segments:     updated.Segments,
+	}
+	if err := storeRevision(id, rev); err != nil {
+		discardRevisions(id, written...)
+		http.Error(w, "Failed to save revision", http.StatusInternalServerError)
+		return
+	}
+	written = append(written, rev)
+
+	// A delete or the janitor may have taken the job away meanwhile; saving
+	// it now would bring its files back
+	jobsMu.Lock()
+	if live, exists := jobs[id]; live != job || job.ExpiredAt != nil {
+		jobsMu.Unlock()
+		discardRevisions(id, written...)
+		if !exists {
+			http.Error(w, "Job not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Job changed while saving the edit", http.StatusConflict)
+		}
+		return
+	}
+	job.Text = updated.Text
+	job.Segments = updated.Segments
+	job.Revisions = append(revisions, rev.RevisionInfo)
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+
+	// Keep the served {id}.txt in step with the latest revision
+	txtPath := filepath.Join(cfg.DataDir, id+".txt")
+	if err := os.WriteFile(txtPath, []byte(transcriptFileText(job, updated)), 0644); err != nil {
+		jobLogger(id).Warn("Failed to update transcript file", "error", err)
+	}
+	jobLogger(id).Info("Saved transcript revision", "stage", "edit", "revision", rev.Number, "author", author)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev.RevisionInfo)
+}
+
+// validateSegments checks edited segments and renumbers them.
+func validateSegments(segments []Segment) error {
+	prevStart := 0.0
+	for i := range segments {
+		seg := &segments[i]
+		if math.IsNaN(seg.Start) || math.IsNaN(seg.End) || seg.Start < 0 || seg.End < seg.Start {
+			return fmt.Errorf("segment %d has invalid times", i)
+		}
+		if seg.Start < prevStart {
+			return fmt.Errorf("segment %d starts before the previous one", i)
+		}
+		prevStart = seg.Start
+		seg.ID = i
+		seg.Text = strings.TrimSpace(seg.Text)
+	}
+	return nil
+}
+
+func joinSegmentText(segments []Segment) string {
+	parts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg.Text != "" {
+			parts = append(parts, seg.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// realignSegments spreads edited text over the existing segments so timing
+// survives corrections. Words are matched against the old words with a diff;
+// kept and replaced words stay in their segment and inserted words join the
+// segment of the word before them. Word timing is kept only for segments
+// whose text did not change, and segments left without words are dropped.
+func realignSegments(segments []Segment, text string) []Segment {
+	var oldWords []string
+	var owner []int
+	for i, seg := range segments {
+		for _, w := range strings.Fields(seg.Text) {
+			oldWords = append(oldWords, w)
+			owner = append(owner, i)
+		}
+	}
+	newWords := strings.Fields(text)
+
+	assigned := make([][]string, len(segments))
+	current := 0
+	for _, e := range diffTokens(oldWords, newWords) {
+		switch e.Kind {
+		case editEqual:
+			current = owner[e.A]
+			assigned[current] = append(assigned[current], newWords[e.B])
+		case editDelete:
+			current = owner[e.A]
+		case editInsert:
+			target := current
+			// Text inserted before the first kept word belongs to the
+			// segment that follows it
+			if e.A < len(owner) && len(assigned[current]) == 0 && owner[e.A] > current {
+				target = owner[e.A]
+			}
+			if len(segments) > 0 {
+				assigned[target] = append(assigned[target], newWords[e.B])
+			}
+		}
+	}
+
+	var out []Segment
+	for i, seg := range segments {
+		if len(assigned[i]) == 0 {
+			continue
+		}
+		newText := strings.Join(assigned[i], " ")
+		if newText != strings.Join(strings.Fields(seg.Text), " ") {
+			seg.Words = nil
+		}
+		seg.Text = newText
+		seg.ID = len(out)
+		out = append(out, seg)
+	}
+	return out
+}
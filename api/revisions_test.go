@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRealignSegments(t *testing.T) {
+	segments := []Segment{
+		{Start: 0, End: 2, Text: "Hello whirled.", Words: []Word{{Start: 0, End: 1, Word: "Hello"}, {Start: 1, End: 2, Word: "whirled."}}},
+		{Start: 2, End: 4, Text: "This is fine.", Words: []Word{{Start: 2, End: 3, Word: "This"}}},
+		{Start: 4, End: 5, Text: "Um."},
+	}
+
+	got := realignSegments(segments, "Hello world. This is really fine.")
+	if len(got) != 2 {
+		t.Fatalf("Expected the emptied segment to be dropped, got %+v", got)
+	}
+	if got[0].Text != "Hello world." || got[0].Words != nil {
+		t.Errorf("Expected corrected first segment without stale words, got %+v", got[0])
+	}
+	if got[1].Text != "This is really fine." || got[1].Start != 2 || got[1].ID != 1 {
+		t.Errorf("Expected insertion to stay in the second segment, got %+v", got[1])
+	}
+}
+
+func TestEditTranscriptRevisions(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	c.DataDir = t.TempDir()
+	cfg = &c
+
+	job := &Job{
+		ID:       "edit-test",
+		Status:   "done",
+		Text:     "Hello whirled.",
+		Segments: []Segment{{Start: 0, End: 2, Text: "Hello whirled."}},
+	}
+	jobsMu.Lock()
+	jobs[job.ID] = job
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, job.ID)
+		jobsMu.Unlock()
+	}()
+
+	body := `{"author": "alice", "note": "typo", "text": "Hello world."}`
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("PUT", "/job/edit-test/transcript", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if job.Text != "Hello world." || len(job.Revisions) != 2 || job.Revisions[1].Author != "alice" {
+		t.Fatalf("Unexpected job after edit: %+v", job)
+	}
+
+	// Exports default to the latest revision but can show the original
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/edit-test/export?format=srt", nil))
+	if !strings.Contains(rec.Body.String(), "Hello world.") {
+		t.Errorf("Expected latest revision in export, got %q", rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/edit-test/export?format=txt&revision=0", nil))
+	if !strings.Contains(rec.Body.String(), "Hello whirled.") {
+		t.Errorf("Expected original machine output, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/edit-test/transcript/diff", nil))
+	var diff struct {
+		From, To int
+		Changes  []diffChunk
+	}
+	json.Unmarshal(rec.Body.Bytes(), &diff)
+	if diff.From != 0 || diff.To != 1 || len(diff.Changes) != 3 || diff.Changes[1].Text != "whirled." {
+		t.Errorf("Unexpected diff %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("PUT", "/job/edit-test/transcript", strings.NewReader(`{"segments": [{"start": 2, "end": 1, "text": "x"}]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid segments to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestEditTranscriptDuringDelete(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	c.DataDir = t.TempDir()
+	c.TempDir = t.TempDir()
+	cfg = &c
+
+	id := "00000000-0000-0000-0000-0000000000f1"
+	job := &Job{ID: id, Status: "done", Text: "Hello whirled."}
+	jobsMu.Lock()
+	jobs[id] = job
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, id)
+		delete(trash, id)
+		jobsMu.Unlock()
+	}()
+
+	// The job is deleted while the edit writes its revisions
+	savedStore := storeRevision
+	defer func() { storeRevision = savedStore }()
+	storeRevision = func(jobID string, rev *Revision) error {
+		if err := saveRevision(jobID, rev); err != nil {
+			return err
+		}
+		if rev.Number == 1 {
+			rec := httptest.NewRecorder()
+			handleJobRoutes(rec, httptest.NewRequest("DELETE", "/job/"+id+"?permanent=true", nil))
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("Expected the delete to succeed, got %d", rec.Code)
+			}
+		}
+		return nil
+	}
+
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("PUT", "/job/"+id+"/transcript", strings.NewReader(`{"text": "Hello world."}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an edit of a deleted job, got %d", rec.Code)
+	}
+	for _, path := range []string{
+		filepath.Join(c.JobsDir, id+".json"),
+		filepath.Join(c.DataDir, id+".txt"),
+		filepath.Join(c.JobsDir, id),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to stay deleted", path)
+		}
+	}
+}
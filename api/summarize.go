@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Summary is the LLM post-processing result stored on a job.
+type Summary struct {
+	Text      string    `json:"text"`
+	KeyPoints []string  `json:"key_points,omitempty"`
+	Chapters  []Chapter `json:"chapters,omitempty"`
+	Model     string    `json:"model"`
+	Created   time.Time `json:"created"`
+}
+
+// Prompt templates. "analyze" runs on every transcript chunk and "combine"
+// merges the partial results when the transcript needed more than one chunk.
+// Both can be overridden with llm_prompts in the config file; templates use
+// text/template syntax with the fields of promptData.
+var defaultPrompts = map[string]string{
+	"analyze": `You summarize video transcripts.{{if .Title}} The video is titled "{{.Title}}".{{end}}{{if gt .Parts 1}} This is part {{.Part}} of {{.Parts}} of the transcript.{{end}}
+Each line starts with its [HH:MM:SS] timestamp.
+
+Reply with only a JSON object of the form
+{"summary": "<one paragraph>", "key_points": ["<point>", ...], "chapters": [{"start": "HH:MM:SS", "title": "<short title>"}, ...]}
+Use between 3 and 8 key points. Chapters mark where the topic changes and must use timestamps from the transcript.
+
+Transcript:
+{{.Transcript}}`,
+
+	"combine": `You merge partial summaries of one video into a single result.{{if .Title}} The video is titled "{{.Title}}".{{end}}
+
+Reply with only a JSON object of the form
+{"summary": "<one or two paragraphs>", "key_points": ["<point>", ...]}
+Use between 3 and 10 key points and drop duplicates.
+
+Partial summaries:
+{{.Summaries}}
+
+Partial key points:
+{{.KeyPoints}}`,
+}
+
+// promptData is what the prompt templates can refer to.
+type promptData struct {
+	Title      string
+	Transcript string
+	Part       int
+	Parts      int
+	Summaries  string
+	KeyPoints  string
+}
+
+// summarizer is the configured LLM post-processor, nil when it is off.
+var summarizer *llmSummarizer
+
+type llmSummarizer struct {
+	chat         *chatClient
+	contextChars int
+	prompts      map[string]*template.Template
+}
+
+func newSummarizer(c *Config) (*llmSummarizer, error) {
+	if c.LLMURL == "" {
+		return nil, nil
+	}
+	prompts, err := parsePrompts(c.LLMPrompts)
+	if err != nil {
+		return nil, err
+	}
+	return &llmSummarizer{
+		chat: &chatClient{
+			url:    c.LLMURL,
+			apiKey: c.LLMAPIKey,
+			model:  c.LLMModel,
+			client: &http.Client{Timeout: 10 * time.Minute},
+		},
+		contextChars: c.LLMContextChars,
+		prompts:      prompts,
+	}, nil
+}
+
+// parsePrompts compiles the default templates with overrides applied.
+func parsePrompts(overrides map[string]string) (map[string]*template.Template, error) {
+	prompts := make(map[string]*template.Template)
+	for name, text := range defaultPrompts {
+		if override, ok := overrides[name]; ok {
+			text = override
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s prompt: %v", name, err)
+		}
+		prompts[name] = tmpl
+	}
+	for name := range overrides {
+		if _, ok := defaultPrompts[name]; !ok {
+			return nil, fmt.Errorf("unknown prompt %q", name)
+		}
+	}
+	return prompts, nil
+}
+
+func (s *llmSummarizer) ask(name string, data promptData, result any) error {
+	var prompt strings.Builder
+	if err := s.prompts[name].Execute(&prompt, data); err != nil {
+		return fmt.Errorf("failed to render %s prompt: %v", name, err)
+	}
+
+	answer, err := s.chat.complete([]chatMessage{{Role: "user", Content: prompt.String()}})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(stripCodeFence(answer)), result); err != nil {
+		return fmt.Errorf("%s answer is not the expected JSON: %v", name, err)
+	}
+	return nil
+}
+
+type partialSummary struct {
+	Summary   string   `json:"summary"`
+	KeyPoints []string `json:"key_points"`
+	Chapters  []struct {
+		Start string `json:"start"`
+		Title string `json:"title"`
+	} `json:"chapters"`
+}
+
+// summarize runs the analyze prompt over every chunk of the transcript and,
+// when there was more than one chunk, merges the results with the combine
+// prompt. Chapters from all chunks are kept as they are already timestamped.
+func (s *llmSummarizer) summarize(logger *slog.Logger, title string, tr *Transcript) (*Summary, error) {
+	chunks := chunkTranscriptLines(transcriptLines(tr), s.contextChars)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("transcript is empty")
+	}
+
+	result := &Summary{Model: s.chat.model, Created: time.Now()}
+	var partials []partialSummary
+	for i, chunk := range chunks {
+		var part partialSummary
+		err := s.ask("analyze", promptData{Title: title, Transcript: chunk, Part: i + 1, Parts: len(chunks)}, &part)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %v", i+1, err)
+		}
+		logger.Debug("Summarized transcript chunk", "chunk", i+1, "chunks", len(chunks))
+		partials = append(partials, part)
+
+		for _, ch := range part.Chapters {
+			start, err := parseTimestamp(ch.Start)
+			if err != nil || strings.TrimSpace(ch.Title) == "" {
+				logger.Warn("Ignoring malformed chapter", "start", ch.Start, "title", ch.Title)
+				continue
+			}
+			result.Chapters = append(result.Chapters, Chapter{Start: start, Title: strings.TrimSpace(ch.Title)})
+		}
+	}
+
+	result.Chapters = mergeChapters(result.Chapters)
+
+	if len(partials) == 1 {
+		result.Text = partials[0].Summary
+		result.KeyPoints = partials[0].KeyPoints
+		return result, nil
+	}
+
+	var summaries, points strings.Builder
+	for i, p := range partials {
+		fmt.Fprintf(&summaries, "Part %d: %s\n", i+1, p.Summary)
+		for _, kp := range p.KeyPoints {
+			fmt.Fprintf(&points, "- %s\n", kp)
+		}
+	}
+	var merged partialSummary
+	if err := s.ask("combine", promptData{Title: title, Parts: len(partials), Summaries: summaries.String(), KeyPoints: points.String()}, &merged); err != nil {
+		return nil, err
+	}
+	result.Text = merged.Summary
+	result.KeyPoints = merged.KeyPoints
+	return result, nil
+}
+
+// mergeChapters orders chapters by start time and drops a chapter that
+// repeats the title of the one before it, which happens when a topic spans
+// two chunks.
+func mergeChapters(chapters []Chapter) []Chapter {
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+	var out []Chapter
+	for _, ch := range chapters {
+		if len(out) > 0 && strings.EqualFold(out[len(out)-1].Title, ch.Title) {
+			continue
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// transcriptLines renders the transcript as "[HH:MM:SS] text" lines, or the
+// plain text when there are no segments.
+func transcriptLines(tr *Transcript) []string {
+	if len(tr.Segments) == 0 {
+		if tr.Text == "" {
+			return nil
+		}
+		return []string{tr.Text}
+	}
+	lines := make([]string, 0, len(tr.Segments))
+	for _, seg := range tr.Segments {
+		line := fmt.Sprintf("[%s] ", formatTimestamp(seg.Start))
+		if seg.Speaker != "" {
+			line += seg.Speaker + ": "
+		}
+		lines = append(lines, line+seg.Text)
+	}
+	return lines
+}
+
+// chunkTranscriptLines groups lines into chunks of at most maxChars so each
+// request fits the model's context window. A single longer line becomes a
+// chunk of its own.
+func chunkTranscriptLines(lines []string, maxChars int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, line := range lines {
+		if current.Len() > 0 && current.Len()+len(line)+1 > maxChars {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteByte('\n')
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// summarizeTranscript runs the optional LLM stage. Failures are logged and
+// leave the job without a summary rather than failing it.
+func summarizeTranscript(job *Job, logger *slog.Logger, tr *Transcript) {
+	jobsMu.RLock()
+	wanted := job.Summarize
+	title := job.Title
+	jobsMu.RUnlock()
+	if !wanted || summarizer == nil {
+		return
+	}
+
+	logger = logger.With("stage", "summarize")
+	updateJobStatusDetailed(job, "summarizing", 88, 100, 90, "")
+	defer metrics.observeStage("summarize", time.Now())
+
+	summary, err := summarizer.summarize(logger, title, tr)
+	if err != nil {
+		logger.Warn("Summarization failed, continuing without summary", "error", err)
+		return
+	}
+
+	jobsMu.Lock()
+	job.Summary = summary
+	jobsMu.Unlock()
+	logger.Info("Generated summary", "key_points", len(summary.KeyPoints), "chapters", len(summary.Chapters))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChunkTranscriptLines(t *testing.T) {
+	lines := []string{strings.Repeat("a", 40), strings.Repeat("b", 40), strings.Repeat("c", 120), "d"}
+	chunks := chunkTranscriptLines(lines, 100)
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d: %q", len(chunks), chunks)
+	}
+	if chunks[0] != lines[0]+"\n"+lines[1] || chunks[1] != lines[2] || chunks[2] != "d" {
+		t.Errorf("Unexpected chunks %q", chunks)
+	}
+}
+
+func TestParsePrompts(t *testing.T) {
+	if _, err := parsePrompts(map[string]string{"analyze": "Summarize {{.Transcript}}"}); err != nil {
+		t.Errorf("Expected valid override, got %v", err)
+	}
+	if _, err := parsePrompts(map[string]string{"analyze": "{{.Transcript"}); err == nil {
+		t.Error("Expected a broken template to be rejected")
+	}
+	if _, err := parsePrompts(map[string]string{"outline": "x"}); err == nil {
+		t.Error("Expected an unknown prompt to be rejected")
+	}
+}
+
+func TestSummarizeChunkedTranscript(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []chatMessage `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Messages[0].Content
+		prompts = append(prompts, prompt)
+
+		answer := `{"summary": "part", "key_points": ["p"], "chapters": [{"start": "00:00:00", "title": "Intro"}]}`
+		if strings.Contains(prompt, "[00:03:00]") {
+			answer = `{"summary": "part", "key_points": ["q"], "chapters": [{"start": "00:03:00", "title": "Outro"}]}`
+		}
+		if strings.HasPrefix(prompt, "You merge") {
+			answer = `{"summary": "Whole video.", "key_points": ["p", "q"]}`
+		}
+		content, _ := json.Marshal(answer)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": ` + string(content) + `}}]}`))
+	}))
+	defer server.Close()
+
+	c := *cfg
+	c.LLMURL = server.URL
+	c.LLMModel = "test-model"
+	c.LLMContextChars = 1000
+	s, err := newSummarizer(&c)
+	if err != nil {
+		t.Fatalf("Failed to create summarizer: %v", err)
+	}
+
+	tr := &Transcript{}
+	for i := 0; i < 40; i++ {
+		tr.Segments = append(tr.Segments, Segment{ID: i, Start: float64(i * 6), Text: strings.Repeat("word ", 10)})
+	}
+
+	summary, err := s.summarize(slog.Default(), "Test video", tr)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(prompts) < 3 || !strings.HasPrefix(prompts[len(prompts)-1], "You merge") {
+		t.Fatalf("Expected chunked analysis followed by combine, got %d prompts", len(prompts))
+	}
+	if !strings.Contains(prompts[0], `titled "Test video"`) {
+		t.Errorf("Expected title in prompt, got %q", prompts[0])
+	}
+	if summary.Text != "Whole video." || len(summary.KeyPoints) != 2 || summary.Model != "test-model" {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if len(summary.Chapters) != 2 || summary.Chapters[1].Start != 180 || summary.Chapters[1].Title != "Outro" {
+		t.Errorf("Expected timestamped chapters from every chunk, got %+v", summary.Chapters)
+	}
+}
+
+func TestConfigRedactsAPIKeys(t *testing.T) {
+	c := *cfg
+	c.LLMAPIKey = "sk-secret"
+	c.TranslatorAPIKey = "lt-secret"
+	data, _ := json.Marshal(c.redacted())
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("Expected API keys to be redacted, got %s", data)
+	}
+}
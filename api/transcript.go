@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Segment is a timed piece of a transcript. Times are in seconds from the
+// start of the full audio.
+type Segment struct {
+	ID      int     `json:"id"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+	Speaker string  `json:"speaker,omitempty"`
+	Words   []Word  `json:"words,omitempty"`
+	// Whisper's estimate that the audio behind the segment is not speech
+	NoSpeechProb float64 `json:"no_speech_prob,omitempty"`
+}
+
+// Word is a single word with its timing, recorded when whisper runs with
+// word timestamps.
+type Word struct {
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"`
+	Word        string  `json:"word"`
+	Probability float64 `json:"probability,omitempty"`
+}
+
+// Transcript is the output of transcribing a file or a chunk of one.
+type Transcript struct {
+	Text     string    `json:"text"`
+	Segments []Segment `json:"segments"`
+	// Language detected by the engine. Probability is only reported by some
+	// engines and is zero otherwise.
+	Language            string  `json:"language,omitempty"`
+	LanguageProbability float64 `json:"language_probability,omitempty"`
+
+	// Per-chunk detections of a chunked transcription
+	Languages []LanguageDetection `json:"-"`
+}
+
+// parseWhisperJSON reads the JSON file written by `whisper --output_format json`.
+func parseWhisperJSON(data []byte) (*Transcript, error) {
+	var raw struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+		Segments []struct {
+			ID    int     `json:"id"`
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+			Words []Word  `json:"words"`
+
+			NoSpeechProb float64 `json:"no_speech_prob"`
+		} `json:"segments"`
+		// Not written by openai-whisper, but by some compatible engines
+		LanguageProbability float64 `json:"language_probability"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse whisper output: %v", err)
+	}
+
+	tr := &Transcript{
+		Text:                strings.TrimSpace(raw.Text),
+		Language:            raw.Language,
+		LanguageProbability: raw.LanguageProbability,
+	}
+	for _, s := range raw.Segments {
+		seg := Segment{
+			ID:    s.ID,
+			Start: s.Start,
+			End:   s.End,
+			Text:  strings.TrimSpace(s.Text),
+
+			NoSpeechProb: s.NoSpeechProb,
+		}
+		for _, w := range s.Words {
+			w.Word = strings.TrimSpace(w.Word)
+			if w.Word != "" {
+				seg.Words = append(seg.Words, w)
+			}
+		}
+		tr.Segments = append(tr.Segments, seg)
+	}
+	return tr, nil
+}
+
+// shift moves every segment of the transcript by offset seconds, turning
+// chunk-relative times into times in the full audio.
+func (tr *Transcript) shift(offset float64) {
+	for i := range tr.Segments {
+		seg := &tr.Segments[i]
+		seg.Start += offset
+		seg.End += offset
+		for j := range seg.Words {
+			seg.Words[j].Start += offset
+			seg.Words[j].End += offset
+		}
+	}
+}
+
+// append adds other to the end of tr, renumbering the appended segments.
+func (tr *Transcript) append(other *Transcript) {
+	if other.Text != "" {
+		if tr.Text != "" {
+			tr.Text += " "
+		}
+		tr.Text += other.Text
+	}
+	for _, s := range other.Segments {
+		s.ID = len(tr.Segments)
+		tr.Segments = append(tr.Segments, s)
+	}
+}
+
+// speakerName returns the display name of a speaker label on a job.
+func speakerName(job *Job, label string) string {
+	if name, ok := job.SpeakerNames[label]; ok && name != "" {
+		return name
+	}
+	return label
+}
+
+// hasSpeakers reports whether any segment carries a speaker label.
+func hasSpeakers(segments []Segment) bool {
+	for _, s := range segments {
+		if s.Speaker != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Chapter is a titled section of a transcript starting at Start seconds.
+type Chapter struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"`
+	Title string  `json:"title"`
+}
+
+// formatTimestamp formats seconds as HH:MM:SS.
+func formatTimestamp(seconds float64) string {
+	s := int64(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// parseTimestamp parses HH:MM:SS, MM:SS or plain seconds.
+func parseTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	total := 0.0
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		total = total*60 + v
+	}
+	return total, nil
+}
+
+// section is a titled run of segments. Segments before the first chapter
+// form an untitled leading section.
+type section struct {
+	Title    string
+	Start    float64
+	End      float64
+	Segments []Segment
+}
+
+// jobChapters returns the chapters to split a job's transcript by: the
+// video's own chapters when it has them, otherwise those from the summary.
+func jobChapters(job *Job) []Chapter {
+	if len(job.Chapters) > 0 {
+		return job.Chapters
+	}
+	if job.Summary != nil {
+		return job.Summary.Chapters
+	}
+	return nil
+}
+
+// splitSections assigns every segment to the chapter its midpoint falls in.
+// Chapters without any segments are left out.
+func splitSections(segments []Segment, chapters []Chapter) []section {
+	if len(chapters) == 0 {
+		return []section{{Segments: segments}}
+	}
+
+	var sections []section
+	current := -2 // -1 is the untitled part before the first chapter
+	for _, seg := range segments {
+		mid := (seg.Start + seg.End) / 2
+		idx := -1
+		for i, ch := range chapters {
+			if ch.Start <= mid {
+				idx = i
+			}
+		}
+		if idx != current {
+			current = idx
+			s := section{Start: seg.Start}
+			if idx >= 0 {
+				s.Title = chapters[idx].Title
+				s.Start = chapters[idx].Start
+			}
+			sections = append(sections, s)
+		}
+		last := &sections[len(sections)-1]
+		last.Segments = append(last.Segments, seg)
+		last.End = seg.End
+		if idx >= 0 && chapters[idx].End > 0 {
+			last.End = chapters[idx].End
+		}
+	}
+	return sections
+}
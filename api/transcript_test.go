@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestParseWhisperJSONAndAppend(t *testing.T) {
+	data := []byte(`{"text": " Hello there. General Kenobi.", "segments": [
+		{"id": 0, "start": 0.0, "end": 1.5, "text": " Hello there."},
+		{"id": 1, "start": 1.5, "end": 3.0, "text": " General Kenobi."}
+	], "language": "en"}`)
+
+	tr, err := parseWhisperJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if tr.Text != "Hello there. General Kenobi." || len(tr.Segments) != 2 || tr.Language != "en" {
+		t.Fatalf("Unexpected transcript: %+v", tr)
+	}
+
+	chunk, _ := parseWhisperJSON(data)
+	chunk.shift(120)
+	tr.append(chunk)
+
+	if len(tr.Segments) != 4 {
+		t.Fatalf("Expected 4 segments, got %d", len(tr.Segments))
+	}
+	last := tr.Segments[3]
+	if last.ID != 3 || last.Start != 121.5 || last.End != 123 {
+		t.Errorf("Expected shifted and renumbered segment, got %+v", last)
+	}
+	if tr.Text != "Hello there. General Kenobi. Hello there. General Kenobi." {
+		t.Errorf("Unexpected joined text %q", tr.Text)
+	}
+}
+
+func TestParseWhisperJSONWords(t *testing.T) {
+	data := []byte(`{"text": " Hi all", "segments": [{"id": 0, "start": 0.5, "end": 1.2, "text": " Hi all",
+		"words": [{"start": 0.5, "end": 0.7, "word": " Hi", "probability": 0.9}, {"start": 0.8, "end": 1.2, "word": " all", "probability": 0.8}]}]}`)
+
+	tr, err := parseWhisperJSON(data)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	tr.shift(60)
+
+	words := tr.Segments[0].Words
+	if len(words) != 2 || words[0].Word != "Hi" || words[1].Start != 60.8 || words[1].Probability != 0.8 {
+		t.Errorf("Unexpected words %+v", words)
+	}
+}
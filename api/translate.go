@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Translator translates a batch of texts into the target language, returning
+// exactly one translation per input in the same order. The implementation is
+// picked by the translator setting.
+type Translator interface {
+	Translate(texts []string, target string) ([]string, error)
+}
+
+// translator is the configured implementation, nil when translation is off.
+var translator Translator
+
+// translateBatchSize is the number of segments sent per request. It keeps
+// requests well inside provider limits while giving the model enough
+// surrounding context to translate sentences split across segments.
+const translateBatchSize = 40
+
+var languageCodePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8})?$`)
+
+func newTranslator(c *Config) (Translator, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	switch c.Translator {
+	case "":
+		return nil, nil
+	case "openai":
+		return &chatTranslator{chat: &chatClient{
+			url:    c.TranslatorURL,
+			apiKey: c.TranslatorAPIKey,
+			model:  c.TranslatorModel,
+			client: client,
+		}}, nil
+	case "libretranslate":
+		return &libreTranslator{url: c.TranslatorURL, apiKey: c.TranslatorAPIKey, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown translator %q", c.Translator)
+	}
+}
+
+// Translation is a job's transcript in another language. Segments mirror the
+// original segments one to one, keeping their timestamps and speakers.
+type Translation struct {
+	Language string    `json:"language"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	Segments []Segment `json:"segments,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+// chatTranslator translates through an OpenAI-compatible chat endpoint. Each
+// batch goes out as a JSON array and must come back as an array of the same
+// length, which is what keeps segments aligned.
+type chatTranslator struct {
+	chat *chatClient
+}
+
+func (t *chatTranslator) Translate(texts []string, target string) ([]string, error) {
+	input, err := json.Marshal(texts)
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err := t.chat.complete([]chatMessage{
+		{Role: "system", Content: fmt.Sprintf("You are a professional translator. Translate every string of the JSON array from the user into the language with code %q. "+
+			"Reply with only a JSON array of the translated strings, with exactly the same number of elements in the same order. "+
+			"Do not merge, split, explain or add anything.", target)},
+		{Role: "user", Content: string(input)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	if err := json.Unmarshal([]byte(stripCodeFence(answer)), &out); err != nil {
+		return nil, fmt.Errorf("translation is not a JSON array: %v", err)
+	}
+	if len(out) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d segments", len(out), len(texts))
+	}
+	return out, nil
+}
+
+// libreTranslator speaks the LibreTranslate /translate API, which accepts an
+// array of texts in q and answers with an array in translatedText.
+type libreTranslator struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func (t *libreTranslator) Translate(texts []string, target string) ([]string, error) {
+	body, err := json.Marshal(map[string]any{
+		"q":       texts,
+		"source":  "auto",
+		"target":  target,
+		"format":  "text",
+		"api_key": t.apiKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("translation request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorOutput))
+		return nil, fmt.Errorf("translation service returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %v", err)
+	}
+	if len(result.TranslatedText) != len(texts) {
+		return nil, fmt.Errorf("got %d translations for %d segments", len(result.TranslatedText), len(texts))
+	}
+	return result.TranslatedText, nil
+}
+
+// translateSegments translates segments in batches, returning copies with the
+// translated text and the original timing and speakers.
+func translateSegments(t Translator, segments []Segment, target string) ([]Segment, error) {
+	out := make([]Segment, len(segments))
+	copy(out, segments)
+
+	for start := 0; start < len(out); start += translateBatchSize {
+		end := min(start+translateBatchSize, len(out))
+		texts := make([]string, end-start)
+		for i := range texts {
+			texts[i] = out[start+i].Text
+		}
+
+		translated, err := t.Translate(texts, target)
+		if err != nil {
+			return nil, fmt.Errorf("segments %d-%d: %v", start+1, end, err)
+		}
+		for i, text := range translated {
+			out[start+i].Text = strings.TrimSpace(text)
+			// Word timing belongs to the original language
+			out[start+i].Words = nil
+		}
+	}
+	return out, nil
+}
+
+// translateJob produces the requested translations one language at a time.
+// It runs outside the transcription queue since it only talks to the
+// translation provider.
+func translateJob(job *Job, languages []string) {
+	logger := jobLogger(job.ID).With("stage", "translate")
+
+	jobsMu.RLock()
+	segments := job.Segments
+	text := job.Text
+	jobsMu.RUnlock()
+
+	// Jobs transcribed before segments were kept only have the plain text
+	if len(segments) == 0 {
+		segments = []Segment{{Text: text}}
+	}
+
+	for _, lang := range languages {
+		setTranslationStatus(job, lang, "translating", "")
+		started := time.Now()
+
+		translated, err := translateSegments(translator, segments, lang)
+		metrics.observeStage("translate", started)
+		if err != nil {
+			logger.Error("Translation failed", "language", lang, "error", err)
+			setTranslationStatus(job, lang, "error", err.Error())
+			continue
+		}
+
+		parts := make([]string, 0, len(translated))
+		for _, seg := range translated {
+			if seg.Text != "" {
+				parts = append(parts, seg.Text)
+			}
+		}
+
+		jobsMu.Lock()
+		tr := job.Translations[lang]
+		tr.Status = "done"
+		tr.Error = ""
+		tr.Text = strings.Join(parts, " ")
+		if len(job.Segments) > 0 {
+			tr.Segments = translated
+		}
+		jobsMu.Unlock()
+		saveJobToDisk(job)
+
+		logger.Info("Translation completed", "language", lang, "segments", len(translated), "duration", time.Since(started))
+	}
+}
+
+func setTranslationStatus(job *Job, lang, status, errMsg string) {
+	jobsMu.Lock()
+	tr := job.Translations[lang]
+	tr.Status = status
+	tr.Error = errMsg
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+}
+
+// handleTranslations serves GET and POST /job/{id}/translations. POST takes
+// {"languages": ["de", "fr"]} and starts translating in the background.
+func handleTranslations(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, POST, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method == "GET" {
+		jobsMu.RLock()
+		defer jobsMu.RUnlock()
+		job, exists := jobs[id]
+		if !exists {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		translations := job.Translations
+		if translations == nil {
+			translations = map[string]*Translation{}
+		}
+		json.NewEncoder(w).Encode(translations)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if translator == nil {
+		http.Error(w, "Translation is not configured on this server", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Languages []string `json:"languages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(payload.Languages) == 0 {
+		http.Error(w, "At least one target language is required", http.StatusBadRequest)
+		return
+	}
+	for _, lang := range payload.Languages {
+		if !languageCodePattern.MatchString(lang) {
+			http.Error(w, fmt.Sprintf("Invalid language code %q", lang), http.StatusBadRequest)
+			return
+		}
+	}
+
+	jobsMu.Lock()
+	job, exists := jobs[id]
+	if !exists {
+		jobsMu.Unlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.Status != "done" {
+		jobsMu.Unlock()
+		http.Error(w, "Job is not finished", http.StatusConflict)
+		return
+	}
+
+	if job.Translations == nil {
+		job.Translations = make(map[string]*Translation)
+	}
+	var started []string
+	for _, lang := range payload.Languages {
+		lang = normalizeLanguage(lang)
+		if tr, ok := job.Translations[lang]; ok && (tr.Status == "queued" || tr.Status == "translating") {
+			continue
+		}
+		job.Translations[lang] = &Translation{Language: lang, Status: "queued", Created: time.Now()}
+		started = append(started, lang)
+	}
+	jobsMu.Unlock()
+	saveJobToDisk(job)
+
+	if len(started) > 0 {
+		go translateJob(job, started)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	jobsMu.RLock()
+	json.NewEncoder(w).Encode(job.Translations)
+	jobsMu.RUnlock()
+}
+
+// normalizeLanguage turns a language code into the form translations are
+// stored under, e.g. pt_BR into pt-br.
+func normalizeLanguage(lang string) string {
+	return strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+}
+
+// translatedJob returns a copy of job whose text and segments are replaced by
+// its translation into lang, for use by the exporters.
+func translatedJob(job *Job, lang string) (*Job, error) {
+	lang = normalizeLanguage(lang)
+	tr, ok := job.Translations[lang]
+	if !ok {
+		return nil, fmt.Errorf("no translation into %q", lang)
+	}
+	if tr.Status != "done" {
+		return nil, fmt.Errorf("translation into %q is %s", lang, tr.Status)
+	}
+
+	localized := *job
+	localized.Text = tr.Text
+	localized.Segments = tr.Segments
+	localized.Translations = nil
+	return &localized, nil
+}
+
+// markInterruptedTranslations fails translations that were in flight when
+// the server stopped, so clients can request them again. It reports whether
+// anything changed.
+func markInterruptedTranslations(job *Job) bool {
+	changed := false
+	for _, tr := range job.Translations {
+		if tr.Status == "queued" || tr.Status == "translating" {
+			tr.Status = "error"
+			tr.Error = "Interrupted by server restart"
+			changed = true
+		}
+	}
+	return changed
+}
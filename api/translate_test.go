@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubTranslator "translates" by prefixing the target language.
+type stubTranslator struct {
+	calls int
+}
+
+func (s *stubTranslator) Translate(texts []string, target string) ([]string, error) {
+	s.calls++
+	out := make([]string, len(texts))
+	for i, text := range texts {
+		out[i] = "[" + target + "] " + text
+	}
+	return out, nil
+}
+
+func TestTranslateSegmentsKeepsTiming(t *testing.T) {
+	segments := make([]Segment, translateBatchSize+5)
+	for i := range segments {
+		segments[i] = Segment{ID: i, Start: float64(i), End: float64(i) + 0.5, Text: "line", Speaker: "SPEAKER_1"}
+	}
+
+	stub := &stubTranslator{}
+	got, err := translateSegments(stub, segments, "de")
+	if err != nil {
+		t.Fatalf("Translation failed: %v", err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("Expected 2 batches, got %d", stub.calls)
+	}
+	last := got[len(got)-1]
+	if last.Text != "[de] line" || last.Start != float64(len(got)-1) || last.Speaker != "SPEAKER_1" {
+		t.Errorf("Unexpected translated segment %+v", last)
+	}
+	if segments[0].Text != "line" {
+		t.Error("Expected original segments to be left untouched")
+	}
+}
+
+func TestHandleTranslationsAndExport(t *testing.T) {
+	savedCfg, savedTranslator := cfg, translator
+	defer func() { cfg, translator = savedCfg, savedTranslator }()
+	c := *cfg
+	c.JobsDir = t.TempDir()
+	cfg = &c
+	translator = &stubTranslator{}
+
+	job := &Job{
+		ID:     "translate-test",
+		Status: "done",
+		Text:   "Hello. Bye.",
+		Segments: []Segment{
+			{ID: 0, Start: 0, End: 1, Text: "Hello."},
+			{ID: 1, Start: 1, End: 2, Text: "Bye."},
+		},
+	}
+	jobsMu.Lock()
+	jobs[job.ID] = job
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, job.ID)
+		jobsMu.Unlock()
+	}()
+
+	req := httptest.NewRequest("POST", "/job/translate-test/translations", strings.NewReader(`{"languages": ["de", "pt_BR"]}`))
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The completion is logged last, after the job is saved
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		log, _ := os.ReadFile(jobLogPath(job.ID))
+		if strings.Count(string(log), "Translation completed") == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Translations did not finish: %s", log)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/translate-test/export?format=srt&lang=de", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "00:00:01,000 --> 00:00:02,000\n[de] Bye.") {
+		t.Errorf("Unexpected translated SRT %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/translate-test/export?format=txt&lang=pt_BR", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "[pt-br] Hello.") {
+		t.Errorf("Expected the pt-br translation for lang=pt_BR, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/translate-test/export?format=json", nil))
+	var doc exportDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON export: %v", err)
+	}
+	if len(doc.Translations["de"]) != 2 || doc.Translations["de"][0].Text != "[de] Hello." {
+		t.Errorf("Expected translations in JSON export, got %+v", doc.Translations)
+	}
+
+	if _, err := os.Stat(c.JobsDir + "/translate-test.json"); err != nil {
+		t.Errorf("Expected job to be saved: %v", err)
+	}
+}
+
+func TestChatTranslator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Missing API key")
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "` + "```json\\n[\\\"Hallo\\\", \\\"Tschüss\\\"]\\n```" + `"}}]}`))
+	}))
+	defer server.Close()
+
+	tr := &chatTranslator{chat: &chatClient{url: server.URL, apiKey: "secret", model: "test", client: server.Client()}}
+	got, err := tr.Translate([]string{"Hello", "Bye"}, "de")
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if len(got) != 2 || got[1] != "Tschüss" {
+		t.Errorf("Unexpected translations %v", got)
+	}
+
+	if _, err := tr.Translate([]string{"Hello"}, "de"); err == nil {
+		t.Error("Expected a length mismatch to fail")
+	}
+}
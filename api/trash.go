@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// trash holds soft-deleted jobs until they are restored or purged. Jobs move
+// between it and jobs, so every other handler simply no longer finds them.
+// It is guarded by jobsMu.
+var trash = make(map[string]*Job)
+
+// trashExpiry returns when a trashed job is purged for good.
+func trashExpiry(job *Job) time.Time {
+	return job.DeletedAt.Add(days(cfg.TrashDays))
+}
+
+// handleDeleteJob serves DELETE /job/{id}. The job goes to the trash for
+// trash_days, or is removed with all its files right away with
+// ?permanent=true or when trash_days is zero. Running jobs cannot be deleted.
+func handleDeleteJob(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "GET, DELETE, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	permanent := r.URL.Query().Get("permanent") == "true" || cfg.TrashDays == 0
+
+	jobsMu.Lock()
+	job, exists := jobs[id]
+	inTrash := false
+	if !exists {
+		job, inTrash = trash[id]
+	}
+	if job == nil || (inTrash && !permanent) {
+		jobsMu.Unlock()
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if jobActive(job) {
+		jobsMu.Unlock()
+		http.Error(w, "Job is still running", http.StatusConflict)
+		return
+	}
+
+	delete(jobs, id)
+	delete(trash, id)
+	if !permanent {
+		now := time.Now()
+		job.DeletedAt = &now
+		trash[id] = job
+	}
+	jobsMu.Unlock()
+
+	logger := jobLogger(id).With("stage", "delete")
+	if permanent {
+		freed := removeJobFiles(id, false)
+		slog.Info("Deleted job", "job_id", id, "stage", "delete", "bytes_freed", freed)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	saveJobToDisk(job)
+	logger.Info("Moved job to trash", "purge_at", trashExpiry(job))
+	w.Header().Set("Content-Type", "application/json")
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleRestoreJob serves POST /job/{id}/restore for jobs still in the trash.
+func handleRestoreJob(w http.ResponseWriter, r *http.Request, id string) {
+	setCORSHeaders(w, "POST, OPTIONS")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jobsMu.Lock()
+	job, exists := trash[id]
+	if !exists {
+		jobsMu.Unlock()
+		http.Error(w, "Job is not in the trash", http.StatusNotFound)
+		return
+	}
+	if time.Now().After(trashExpiry(job)) {
+		jobsMu.Unlock()
+		http.Error(w, "Grace period for restoring the job has passed", http.StatusGone)
+		return
+	}
+	delete(trash, id)
+	job.DeletedAt = nil
+	jobs[id] = job
+	jobsMu.Unlock()
+
+	saveJobToDisk(job)
+	jobLogger(id).Info("Restored job from trash", "stage", "delete")
+
+	w.Header().Set("Content-Type", "application/json")
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleGetTrash serves GET /jobs/trash, most recently deleted first.
+func handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, "GET, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	owner := keyFingerprint(requestAPIKey(r))
+	jobsMu.RLock()
+	defer jobsMu.RUnlock()
+	trashed := make([]*Job, 0, len(trash))
+	for _, job := range trash {
+		if visibleTo(job, owner) {
+			trashed = append(trashed, job)
+		}
+	}
+	sort.Slice(trashed, func(i, j int) bool { return trashed[i].DeletedAt.After(*trashed[j].DeletedAt) })
+	json.NewEncoder(w).Encode(trashed)
+}
+
+// purgeTrash removes trashed jobs whose grace period has passed.
+func purgeTrash(now time.Time) (int64, int) {
+	var expired []string
+	jobsMu.Lock()
+	for id, job := range trash {
+		if now.After(trashExpiry(job)) {
+			expired = append(expired, id)
+			delete(trash, id)
+		}
+	}
+	jobsMu.Unlock()
+
+	var freed int64
+	for _, id := range expired {
+		freed += removeJobFiles(id, false)
+	}
+	return freed, len(expired)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestoreJob(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.JobsDir = t.TempDir()
+	c.TempDir = t.TempDir()
+	c.TrashDays = 7
+	cfg = &c
+
+	id := "00000000-0000-0000-0000-0000000000d1"
+	job := &Job{ID: id, Status: "done", Created: time.Now()}
+	jobsMu.Lock()
+	jobs[id] = job
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(jobs, id)
+		delete(trash, id)
+		jobsMu.Unlock()
+	}()
+
+	files := []string{
+		filepath.Join(c.DataDir, id+".txt"),
+		filepath.Join(c.DataDir, id+".opus"),
+		filepath.Join(c.JobsDir, id+".log"),
+		filepath.Join(c.JobsDir, id, "revisions", "0001.json"),
+		filepath.Join(c.TempDir, id+".wav"),
+	}
+	for _, f := range files {
+		os.MkdirAll(filepath.Dir(f), 0755)
+		touch(t, f, time.Now())
+	}
+
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("DELETE", "/job/"+id, nil))
+	if rec.Code != http.StatusOK || job.DeletedAt == nil {
+		t.Fatalf("Expected the job to be trashed, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("GET", "/job/"+id, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected trashed job to be hidden, got %d", rec.Code)
+	}
+	if _, err := os.Stat(files[0]); err != nil {
+		t.Error("Expected trashed job to keep its files")
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("POST", "/job/"+id+"/restore", nil))
+	if rec.Code != http.StatusOK || job.DeletedAt != nil {
+		t.Fatalf("Expected the job to be restored, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("DELETE", "/job/"+id+"?permanent=true", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rec.Code)
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", f)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(c.JobsDir, id)); !os.IsNotExist(err) {
+		t.Error("Expected the job directory to be removed")
+	}
+}
+
+func TestTrashGracePeriod(t *testing.T) {
+	saved := cfg
+	defer func() { cfg = saved }()
+	c := *cfg
+	c.DataDir = t.TempDir()
+	c.JobsDir = t.TempDir()
+	c.TrashDays = 1
+	cfg = &c
+
+	id := "00000000-0000-0000-0000-0000000000d2"
+	deleted := time.Now().Add(-48 * time.Hour)
+	jobsMu.Lock()
+	trash[id] = &Job{ID: id, Status: "done", DeletedAt: &deleted}
+	jobsMu.Unlock()
+	defer func() {
+		jobsMu.Lock()
+		delete(trash, id)
+		jobsMu.Unlock()
+	}()
+
+	rec := httptest.NewRecorder()
+	handleJobRoutes(rec, httptest.NewRequest("POST", "/job/"+id+"/restore", nil))
+	if rec.Code != http.StatusGone {
+		t.Errorf("Expected 410 after the grace period, got %d", rec.Code)
+	}
+
+	if _, purged := purgeTrash(time.Now()); purged != 1 {
+		t.Errorf("Expected the expired job to be purged, got %d", purged)
+	}
+	jobsMu.RLock()
+	_, exists := trash[id]
+	jobsMu.RUnlock()
+	if exists {
+		t.Error("Expected the job to leave the trash")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// wavFile gives random access to the samples of a 16-bit PCM WAV file, as
+// produced by the ffmpeg stage, without loading hours of audio into memory.
+type wavFile struct {
+	f          *os.File
+	dataOffset int64
+	dataSize   int64
+	channels   int
+	sampleRate int
+}
+
+func openWAV(path string) (*wavFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := parseWAVHeader(f, path)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func parseWAVHeader(f *os.File, path string) (*wavFile, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read WAV header: %v", err)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%s is not a WAV file", path)
+	}
+
+	w := &wavFile{f: f}
+	var bitsPerSample int
+	offset := int64(12)
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(f, chunk[:]); err != nil {
+			return nil, fmt.Errorf("no data chunk in %s", path)
+		}
+		offset += 8
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+
+		switch id {
+		case "fmt ":
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(f, buf); err != nil {
+				return nil, fmt.Errorf("failed to read WAV format: %v", err)
+			}
+			if len(buf) < 16 || binary.LittleEndian.Uint16(buf[0:2]) != 1 {
+				return nil, fmt.Errorf("%s is not PCM", path)
+			}
+			w.channels = int(binary.LittleEndian.Uint16(buf[2:4]))
+			w.sampleRate = int(binary.LittleEndian.Uint32(buf[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(buf[14:16]))
+		case "data":
+			if w.channels == 0 || bitsPerSample != 16 {
+				return nil, fmt.Errorf("%s must be 16-bit PCM", path)
+			}
+			info, err := f.Stat()
+			if err != nil {
+				return nil, err
+			}
+			w.dataOffset = offset
+			w.dataSize = info.Size() - offset
+			// ffmpeg writes a placeholder size when streaming to a pipe
+			if size > 0 && size != 0xFFFFFFFF && size < w.dataSize {
+				w.dataSize = size
+			}
+			return w, nil
+		}
+
+		offset += size + size%2
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (w *wavFile) Close() error {
+	return w.f.Close()
+}
+
+// duration returns the length of the audio in seconds.
+func (w *wavFile) duration() float64 {
+	return float64(w.dataSize/int64(2*w.channels)) / float64(w.sampleRate)
+}
+
+// readRange returns the samples between start and end seconds of the first
+// channel, scaled to [-1, 1].
+func (w *wavFile) readRange(start, end float64) ([]float32, error) {
+	frame := int64(2 * w.channels)
+	total := w.dataSize / frame
+	from := clampFrame(int64(start*float64(w.sampleRate)), total)
+	to := clampFrame(int64(end*float64(w.sampleRate)), total)
+	if to <= from {
+		return nil, nil
+	}
+
+	data := make([]byte, (to-from)*frame)
+	if _, err := w.f.ReadAt(data, w.dataOffset+from*frame); err != nil && err != io.EOF {
+		return nil, err
+	}
+	samples := make([]float32, to-from)
+	for i := range samples {
+		v := int16(binary.LittleEndian.Uint16(data[int64(i)*frame:]))
+		samples[i] = float32(v) / 32768
+	}
+	return samples, nil
+}
+
+func clampFrame(n, total int64) int64 {
+	if n < 0 {
+		return 0
+	}
+	if n > total {
+		return total
+	}
+	return n
+}
+
+// writeWAV writes mono 16-bit PCM samples to path.
+func writeWAV(path string, samples []float32, sampleRate int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dataSize := uint32(len(samples) * 2)
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], 36+dataSize)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], 1)
+	binary.LittleEndian.PutUint32(header[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(header[32:], 2)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], dataSize)
+	if _, err := f.Write(header); err != nil {
+		return err
+	}
+
+	data := make([]byte, dataSize)
+	for i, s := range samples {
+		if s > 1 {
+			s = 1
+		} else if s < -1 {
+			s = -1
+		}
+		binary.LittleEndian.PutUint16(data[i*2:], uint16(int16(s*32767)))
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// bleepFrequency and bleepLevel define the tone that replaces redacted audio.
+const (
+	bleepFrequency = 1000.0
+	bleepLevel     = 0.3
+)
+
+// bleepWAV overwrites the given time ranges of a 16-bit PCM WAV file in place
+// with a tone.
+func bleepWAV(path string, ranges [][2]float64) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := parseWAVHeader(f, path)
+	if err != nil {
+		return err
+	}
+	frame := int64(2 * w.channels)
+	total := w.dataSize / frame
+
+	for _, r := range ranges {
+		from := clampFrame(int64(r[0]*float64(w.sampleRate)), total)
+		to := clampFrame(int64(r[1]*float64(w.sampleRate)+0.5), total)
+		if to <= from {
+			continue
+		}
+		data := make([]byte, (to-from)*frame)
+		for i := int64(0); i < to-from; i++ {
+			v := bleepLevel * math.Sin(2*math.Pi*bleepFrequency*float64(i)/float64(w.sampleRate))
+			sample := uint16(int16(v * 32767))
+			for c := int64(0); c < int64(w.channels); c++ {
+				binary.LittleEndian.PutUint16(data[i*frame+2*c:], sample)
+			}
+		}
+		if _, err := f.WriteAt(data, w.dataOffset+from*frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}